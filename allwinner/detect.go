@@ -104,6 +104,11 @@ func (d *detectionS) do() {
 				if strings.Contains(c, "sun50i-h5") {
 					d.isH5 = true
 				}
+				// TODO: H6 ("sun50i-h6") and H616/H618 ("sun50i-h616",
+				// "sun50i-h618") are not supported yet. They use a different GPIO
+				// register layout (base 0x0300B000) and need their own pin mapping
+				// before they can be detected here; matching only on the compatible
+				// string would not be enough to tell H616 and H618 apart anyway.
 			}
 			d.isAllwinner = d.isA64 || d.isR8 || d.isA20 || d.isH3 || d.isH5
 
@@ -347,6 +347,14 @@ func (p *Pin) DefaultPull() gpio.Pull {
 	return p.defaultPull
 }
 
+// SupportsEdge returns true if the pin supports interrupt based edge
+// detection.
+//
+// When false, In() returns an error for any edge other than gpio.NoEdge.
+func (p *Pin) SupportsEdge() bool {
+	return p.available && p.supportEdge
+}
+
 // Out implements gpio.PinOut.
 func (p *Pin) Out(l gpio.Level) error {
 	if !p.available {
@@ -298,6 +298,14 @@ func (p *PinPL) DefaultPull() gpio.Pull {
 	return p.defaultPull
 }
 
+// SupportsEdge returns true if the pin supports interrupt based edge
+// detection.
+//
+// All the PL pins are interrupt capable.
+func (p *PinPL) SupportsEdge() bool {
+	return p.available
+}
+
 // Out implements gpio.PinOut.
 func (p *PinPL) Out(l gpio.Level) error {
 	if !p.available {
@@ -6,8 +6,12 @@ package ftdi
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash/crc32"
 	"strconv"
+	"strings"
 	"sync"
 
 	"periph.io/x/conn/v3"
@@ -16,6 +20,7 @@ import (
 	"periph.io/x/conn/v3/i2c"
 	"periph.io/x/conn/v3/physic"
 	"periph.io/x/conn/v3/spi"
+	"periph.io/x/conn/v3/uart"
 )
 
 // PinStreamOut is a gpio pin that supports raw data stream output.
@@ -43,6 +48,18 @@ type Info struct {
 	// DevID is the product ID from the USB descriptor information. It is
 	// expected to be one of 0x6001, 0x6006, 0x6010, 0x6014.
 	DevID uint16
+	// Serial is the serial number stored in the EEPROM.
+	//
+	// It is only filled by ListDescriptors().
+	Serial string
+	// Desc is the product description stored in the EEPROM.
+	//
+	// It is only filled by ListDescriptors().
+	Desc string
+
+	// TODO(maruel): Report whether the device negotiated USB high speed. It
+	// requires FT_GetDeviceInfoList() flags (FT_FLAGS_HISPEED), which
+	// periph.io/x/d2xx doesn't expose yet.
 }
 
 // Dev represents one FTDI device.
@@ -52,13 +69,39 @@ type Info struct {
 // The device may also export one or multiple of I²C, SPI buses. You need to
 // either cast into the right hardware, but more simply use the i2creg / spireg
 // bus/port registries.
+//
+// # Concurrency
+//
+// All() returns the same Dev instances to every caller. The individual
+// methods are safe to call concurrently, but a device only supports one user
+// of each of its buses at a time; for example a second call to SPI() fails
+// while the first port is in use. Code sharing a device across goroutines
+// must coordinate via Acquire() and Release().
 type Dev interface {
 	// conn.Resource
 	String() string
 	Halt() error
+	// Close halts the device, removes it from the gpioreg, pinreg, i2creg,
+	// spireg and uartreg registries and releases its handle. The device is
+	// unusable afterward.
+	Close() error
+
+	// Acquire blocks until the caller has exclusive access to the device.
+	//
+	// It is purely advisory; the other methods do not check it. Release()
+	// must be called once done.
+	Acquire()
+	// Release releases the exclusive access obtained via Acquire().
+	Release()
+	// SetCloseBehavior selects whether Halt() resets the GPIOs as inputs, the
+	// default, or leaves them driving their last value.
+	SetCloseBehavior(reset bool)
 
 	// Info returns information about an opened device.
 	Info(i *Info)
+	// Summary returns a one line description of the device and its current
+	// configuration, e.g. to include in logs or bug reports.
+	Summary() string
 
 	// Header returns the GPIO pins exposed on the chip.
 	Header() []gpio.PinIO
@@ -67,6 +110,9 @@ type Dev interface {
 	//
 	// The device defaults to its fastest speed.
 	SetSpeed(f physic.Frequency) error
+	// SetUSBTransferSize sets the USB transfer sizes to tune the latency and
+	// throughput tradeoff. It clears the device buffers.
+	SetUSBTransferSize(in, out int) error
 
 	// EEPROM returns the EEPROM content.
 	EEPROM(ee *EEPROM) error
@@ -81,6 +127,30 @@ type Dev interface {
 	//
 	// If the length of ua is less than the available space, is it zero extended.
 	WriteUserArea(ua []byte) error
+	// ReadUserAreaChecked reads back the data stored by
+	// WriteUserAreaChecked(). It fails if the content is corrupted or was not
+	// written by WriteUserAreaChecked().
+	ReadUserAreaChecked() ([]byte, error)
+	// WriteUserAreaChecked stores data in the user area in the EEPROM, prefixed
+	// with a magic byte, its length and CRC32 so a partial write can be
+	// detected.
+	WriteUserAreaChecked(data []byte) error
+	// ReadEEPROMWord reads the 16 bits word at the word offset in the EEPROM.
+	//
+	// It is not supported yet, as periph.io/x/d2xx doesn't expose FT_ReadEE().
+	ReadEEPROMWord(offset uint16) (uint16, error)
+	// WriteEEPROMWord writes the 16 bits word at the word offset in the EEPROM.
+	// Must be used carefully.
+	WriteEEPROMWord(offset uint16, value uint16) error
+	// DecodeEEPROM reads the EEPROM and returns it decoded as the struct
+	// matching the device type, e.g. *EEPROMFT232H for a FT232H.
+	DecodeEEPROM() (interface{}, error)
+	// EnableRS485 programs the CBus pin cbusPin as TXDEN in the EEPROM, so it
+	// drives the direction of a RS485 transceiver. Must be used carefully.
+	EnableRS485(cbusPin int) error
+	// SetPowerConfig programs the USB power configuration in the EEPROM. Must
+	// be used carefully.
+	SetPowerConfig(selfPowered bool, maxPowerMA int, remoteWakeup bool) error
 }
 
 // broken represents a device that couldn't be opened correctly.
@@ -90,6 +160,8 @@ type broken struct {
 	index int
 	err   error
 	name  string
+	// info is the USB descriptor information, when it could be retrieved.
+	info Info
 }
 
 func (b *broken) String() string {
@@ -100,10 +172,33 @@ func (b *broken) Halt() error {
 	return nil
 }
 
+func (b *broken) Close() error {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.removeLocked(b)
+	return nil
+}
+
+func (b *broken) Acquire() {
+}
+
+func (b *broken) Release() {
+}
+
+func (b *broken) SetCloseBehavior(reset bool) {
+}
+
+// Info returns the USB descriptor information when the device could be
+// opened but failed to initialize. Opened is always false.
 func (b *broken) Info(i *Info) {
+	*i = b.info
 	i.Opened = false
 }
 
+func (b *broken) Summary() string {
+	return fmt.Sprintf("%s(broken: %v)", b.name, b.err)
+}
+
 func (b *broken) Header() []gpio.PinIO {
 	return nil
 }
@@ -112,6 +207,10 @@ func (b *broken) SetSpeed(f physic.Frequency) error {
 	return b.err
 }
 
+func (b *broken) SetUSBTransferSize(in, out int) error {
+	return b.err
+}
+
 func (b *broken) EEPROM(ee *EEPROM) error {
 	return b.err
 }
@@ -132,14 +231,43 @@ func (b *broken) WriteUserArea(ua []byte) error {
 	return b.err
 }
 
+func (b *broken) ReadUserAreaChecked() ([]byte, error) {
+	return nil, b.err
+}
+
+func (b *broken) WriteUserAreaChecked(data []byte) error {
+	return b.err
+}
+
+func (b *broken) ReadEEPROMWord(offset uint16) (uint16, error) {
+	return 0, b.err
+}
+
+func (b *broken) WriteEEPROMWord(offset uint16, value uint16) error {
+	return b.err
+}
+
+func (b *broken) DecodeEEPROM() (interface{}, error) {
+	return nil, b.err
+}
+
+func (b *broken) EnableRS485(cbusPin int) error {
+	return b.err
+}
+
+func (b *broken) SetPowerConfig(selfPowered bool, maxPowerMA int, remoteWakeup bool) error {
+	return b.err
+}
+
 // generic represents a generic FTDI device.
 //
 // It is used for the models that this package doesn't fully support yet.
 type generic struct {
 	// Immutable after initialization.
-	index int
-	h     *handle
-	name  string
+	index  int
+	h      *handle
+	name   string
+	serial string // Read from the EEPROM when the device is opened.
 }
 
 func (f *generic) String() string {
@@ -148,9 +276,54 @@ func (f *generic) String() string {
 
 // Halt implements conn.Resource.
 //
-// This halts all operations going through this device.
+// This aborts all the in-flight transfers going through this device. Unlike a
+// device reset, the USB connection is preserved.
 func (f *generic) Halt() error {
-	return f.h.Reset()
+	f.h.abort()
+	return nil
+}
+
+// Close halts the device, removes it from the registries and releases its
+// handle. The device is unusable afterward.
+func (f *generic) Close() error {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	for _, d := range drv.all {
+		if h, ok := d.(interface{ getHandle() *handle }); ok && h.getHandle() == f.h {
+			return drv.closeLocked(d)
+		}
+	}
+	// Not enumerated by the driver.
+	f.h.abort()
+	return f.h.Close()
+}
+
+func (f *generic) getHandle() *handle {
+	return f.h
+}
+
+func (f *generic) getIndex() int {
+	return f.index
+}
+
+// Acquire blocks until the caller has exclusive access to the device.
+func (f *generic) Acquire() {
+	f.h.owner.Lock()
+}
+
+// Release releases the exclusive access obtained via Acquire().
+func (f *generic) Release() {
+	f.h.owner.Unlock()
+}
+
+// SetCloseBehavior selects whether Halt() resets the GPIOs as inputs, the
+// default, or leaves them driving their last value.
+//
+// Leaving the GPIOs as-is is useful when a pin holds another chip in or out
+// of reset after the program exits. Note that the FTDI device still resets
+// all its pins when it is disconnected from USB or power cycled.
+func (f *generic) SetCloseBehavior(reset bool) {
+	f.h.setKeepPins(!reset)
 }
 
 // Info returns information about an opened device.
@@ -161,6 +334,28 @@ func (f *generic) Info(i *Info) {
 	i.DevID = f.h.devID
 }
 
+// Summary returns the device type, serial number, bit mode and clock.
+func (f *generic) Summary() string {
+	return f.summary(nil)
+}
+
+// summary returns the description of the device, including the buses in use.
+func (f *generic) summary(buses []string) string {
+	serial := f.serial
+	if serial == "" {
+		serial = "unknown"
+	}
+	mode, clock := f.h.state()
+	s := fmt.Sprintf("%s(type=%s, serial=%s, mode=%s", f.name, f.h.t, serial, mode)
+	if len(buses) != 0 {
+		s += ", bus=" + strings.Join(buses, "+")
+	}
+	if clock != 0 {
+		s += ", clock=" + clock.String()
+	}
+	return s + ")"
+}
+
 // Header returns the GPIO pins exposed on the chip.
 func (f *generic) Header() []gpio.PinIO {
 	return nil
@@ -171,6 +366,16 @@ func (f *generic) SetSpeed(freq physic.Frequency) error {
 	return f.h.SetBaudRate(freq)
 }
 
+// SetUSBTransferSize sets the USB request transfer sizes. in and out must be
+// a multiple of 64 between 64 and 65536; out can be 0 to keep the default.
+//
+// Smaller sizes reduce the latency of small transfers, larger sizes increase
+// the throughput of bulk transfers. It clears the device buffers, so it must
+// not be called while a transfer is in progress.
+func (f *generic) SetUSBTransferSize(in, out int) error {
+	return f.h.SetUSBTransferSize(in, out)
+}
+
 func (f *generic) EEPROM(ee *EEPROM) error {
 	return f.h.ReadEEPROM(ee)
 	/*
@@ -211,6 +416,173 @@ func (f *generic) WriteUserArea(ua []byte) error {
 	return f.h.WriteUA(ua)
 }
 
+const (
+	// userAreaMagic is the first byte of the header prepended by
+	// WriteUserAreaChecked(). It is neither 0x00 nor 0xFF, so an erased user
+	// area is not mistaken for empty data, since the CRC32 of no data is 0.
+	userAreaMagic = 0xA5
+	// userAreaHeader is the size of the header prepended by
+	// WriteUserAreaChecked(): userAreaMagic, the data length as a little
+	// endian uint16 and its IEEE CRC32 as a little endian uint32.
+	userAreaHeader = 7
+)
+
+func (f *generic) ReadUserAreaChecked() ([]byte, error) {
+	ua, err := f.h.ReadUA()
+	if err != nil {
+		return nil, err
+	}
+	if len(ua) < userAreaHeader {
+		return nil, errors.New("ftdi: user area is too small")
+	}
+	if ua[0] != userAreaMagic {
+		return nil, errors.New("ftdi: user area is not initialized; invalid header")
+	}
+	l := int(binary.LittleEndian.Uint16(ua[1:]))
+	if l > len(ua)-userAreaHeader {
+		return nil, errors.New("ftdi: user area is corrupted; invalid length")
+	}
+	data := ua[userAreaHeader : userAreaHeader+l]
+	if crc32.ChecksumIEEE(data) != binary.LittleEndian.Uint32(ua[3:]) {
+		return nil, errors.New("ftdi: user area is corrupted; CRC mismatch")
+	}
+	return data, nil
+}
+
+func (f *generic) WriteUserAreaChecked(data []byte) error {
+	if len(data) > 0xFFFF {
+		return errors.New("ftdi: data is too large")
+	}
+	ua := make([]byte, userAreaHeader+len(data))
+	ua[0] = userAreaMagic
+	binary.LittleEndian.PutUint16(ua[1:], uint16(len(data)))
+	binary.LittleEndian.PutUint32(ua[3:], crc32.ChecksumIEEE(data))
+	copy(ua[userAreaHeader:], data)
+	return f.h.WriteUA(ua)
+}
+
+// ReadEEPROMWord is not supported.
+//
+// TODO(maruel): periph.io/x/d2xx doesn't expose FT_ReadEE() yet. The raw
+// EEPROM content returned by FT_EEPROM_Read() is the decoded FT_EEPROM_xxx
+// structure, not the physical words written by WriteEEPROMWord(), so it can't
+// be used instead.
+func (f *generic) ReadEEPROMWord(offset uint16) (uint16, error) {
+	return 0, errors.New("ftdi: reading an EEPROM word is not supported by periph.io/x/d2xx")
+}
+
+// WriteEEPROMWord writes the 16 bits word at the word offset in the EEPROM.
+//
+// This is meant to experiment with devices whose EEPROM layout is not known
+// yet. Writing the wrong value may render the device unusable until the
+// EEPROM is erased.
+func (f *generic) WriteEEPROMWord(offset uint16, value uint16) error {
+	return f.h.WriteEEPROMWord(offset, value)
+}
+
+// DecodeEEPROM reads the EEPROM and returns it decoded as the struct
+// matching the device type.
+//
+// It returns a *EEPROMFT232H, *EEPROMFT2232H or *EEPROMFT232R for the known
+// device types, and the common *EEPROMHeader for the other ones.
+func (f *generic) DecodeEEPROM() (interface{}, error) {
+	var ee EEPROM
+	if err := f.h.ReadEEPROM(&ee); err != nil {
+		return nil, err
+	}
+	var out interface{}
+	switch f.h.t {
+	case DevTypeFT232H:
+		if e := ee.AsFT232H(); e != nil {
+			out = e
+		}
+	case DevTypeFT2232H:
+		if e := ee.AsFT2232H(); e != nil {
+			out = e
+		}
+	case DevTypeFT232R:
+		if e := ee.AsFT232R(); e != nil {
+			out = e
+		}
+	default:
+		if e := ee.AsHeader(); e != nil {
+			out = e
+		}
+	}
+	if out == nil {
+		return nil, errors.New("ftdi: unexpected EEPROM size")
+	}
+	return out, nil
+}
+
+// EnableRS485 programs the CBus pin cbusPin as TXDEN in the EEPROM.
+//
+// TXDEN is asserted one bit time before the start bit up to the end of the
+// stop bit, which is what RS485 level converters need to enable their line
+// driver while transmitting. It is supported on C0~C6, C8 and C9 on the
+// FT232H and on C0~C4 on the FT232R.
+func (f *generic) EnableRS485(cbusPin int) error {
+	var ee EEPROM
+	if err := f.h.ReadEEPROM(&ee); err != nil {
+		return err
+	}
+	switch f.h.t {
+	case DevTypeFT232H:
+		e := ee.AsFT232H()
+		if e == nil {
+			return errors.New("ftdi: unexpected EEPROM size")
+		}
+		pins := [...]*FT232hCBusMux{&e.Cbus0, &e.Cbus1, &e.Cbus2, &e.Cbus3, &e.Cbus4, &e.Cbus5, &e.Cbus6, nil, &e.Cbus8, &e.Cbus9}
+		if cbusPin < 0 || cbusPin >= len(pins) || pins[cbusPin] == nil {
+			return fmt.Errorf("ftdi: C%d doesn't support TXDEN; use one of C0~C6, C8 or C9", cbusPin)
+		}
+		*pins[cbusPin] = FT232hCBusTxdEnable
+	case DevTypeFT232R:
+		e := ee.AsFT232R()
+		if e == nil {
+			return errors.New("ftdi: unexpected EEPROM size")
+		}
+		pins := [...]*FT232rCBusMux{&e.Cbus0, &e.Cbus1, &e.Cbus2, &e.Cbus3, &e.Cbus4}
+		if cbusPin < 0 || cbusPin >= len(pins) {
+			return fmt.Errorf("ftdi: C%d doesn't support TXDEN; use one of C0~C4", cbusPin)
+		}
+		*pins[cbusPin] = FT232rCBusTxdEnable
+	default:
+		return fmt.Errorf("ftdi: RS485 is not supported on %s", f.h.t)
+	}
+	return f.h.WriteEEPROM(&ee)
+}
+
+// SetPowerConfig programs the USB power configuration in the EEPROM.
+//
+// selfPowered reports to the host whether the device has its own power
+// supply. maxPowerMA is the maximum current drawn from the USB bus, up to
+// 500mA. When remoteWakeup is true, pulling RI# low wakes up the host while
+// the USB bus is suspended.
+func (f *generic) SetPowerConfig(selfPowered bool, maxPowerMA int, remoteWakeup bool) error {
+	if maxPowerMA <= 0 || maxPowerMA > 500 {
+		return fmt.Errorf("ftdi: invalid maximum power %dmA; must be between 1 and 500", maxPowerMA)
+	}
+	var ee EEPROM
+	if err := f.h.ReadEEPROM(&ee); err != nil {
+		return err
+	}
+	hdr := ee.AsHeader()
+	if hdr == nil {
+		return errors.New("ftdi: unexpected EEPROM size")
+	}
+	hdr.SelfPowered = 0
+	if selfPowered {
+		hdr.SelfPowered = 1
+	}
+	hdr.MaxPower = uint16(maxPowerMA)
+	hdr.RemoteWakeup = 0
+	if remoteWakeup {
+		hdr.RemoteWakeup = 1
+	}
+	return f.h.WriteEEPROM(&ee)
+}
+
 //
 
 func newFT232H(g generic) (*FT232H, error) {
@@ -319,12 +691,37 @@ type FT232H struct {
 	mu       sync.Mutex
 	usingI2C bool
 	usingSPI bool
+	usingMCU bool
 	i        i2cBus
 	s        spiMPSEEPort
 	// TODO(maruel): Technically speaking, a SPI port could be hacked up too in
 	// sync bit-bang but there's less point when MPSEE is available.
 }
 
+// Halt implements conn.Resource.
+//
+// It aborts the in-flight transfers and sets all the GPIOs as inputs, unless
+// disabled via SetCloseBehavior(). The device is not reset, so it is safe to
+// call in the middle of a SPI or I²C transaction; the bus pins are driven
+// again on the next transaction.
+//
+// Only a transfer already waiting for the device's reply is aborted; a
+// transaction that didn't reach this point yet completes normally.
+func (f *FT232H) Halt() error {
+	f.h.abort()
+	if f.h.keepPinsOnHalt() {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dbus.direction = 0
+	f.cbus.direction = 0
+	if err := f.h.MPSSEDBus(f.dbus.direction, f.dbus.value); err != nil {
+		return err
+	}
+	return f.h.MPSSECBus(f.cbus.direction, f.cbus.value)
+}
+
 // Header returns the GPIO pins exposed on the chip.
 func (f *FT232H) Header() []gpio.PinIO {
 	out := make([]gpio.PinIO, len(f.hdr))
@@ -332,6 +729,24 @@ func (f *FT232H) Header() []gpio.PinIO {
 	return out
 }
 
+// Summary returns the device type, serial number, bit mode, the buses in use
+// and the clock.
+func (f *FT232H) Summary() string {
+	var buses []string
+	f.mu.Lock()
+	if f.usingI2C {
+		buses = append(buses, "I²C")
+	}
+	if f.usingSPI {
+		buses = append(buses, "SPI")
+	}
+	if f.usingMCU {
+		buses = append(buses, "MCU")
+	}
+	f.mu.Unlock()
+	return f.summary(buses)
+}
+
 func (f *FT232H) SetSpeed(freq physic.Frequency) error {
 	// TODO(maruel): When using MPSEE, use the MPSEE command. If using sync
 	// bit-bang, use SetBaudRate().
@@ -366,6 +781,102 @@ func (f *FT232H) DBusRead() (byte, error) {
 	return f.h.MPSSEDBusRead()
 }
 
+// ReadAllGPIO reads the values of D0 to D7 and C0 to C7.
+//
+// It is faster than calling DBusRead() then CBusRead() since both values are
+// retrieved in a single USB round trip.
+func (f *FT232H) ReadAllGPIO() (dbus, cbus byte, err error) {
+	return f.h.MPSSEBusRead()
+}
+
+// SetClockPhase selects 3 phases data clocking when threePhase is true, and
+// the normal 2 phases data clocking otherwise.
+//
+// With 3 phases clocking, data is valid on both clock edges. It is used by the
+// I²C implementation but some custom protocols need it too.
+func (f *FT232H) SetClockPhase(threePhase bool) error {
+	cmd := [1]byte{clock2Phase}
+	if threePhase {
+		cmd[0] = clock3Phase
+	}
+	_, err := f.h.Write(cmd[:])
+	return err
+}
+
+// WaitForPin stalls the MPSSE engine until the D-bus pin is at level.
+//
+// The wait is done in hardware, so the MPSSE commands queued afterward are
+// only processed once the pin changes, without polling over USB. The MPSSE
+// engine can only wait on D5 (GPIOL1), so pin must be 5.
+func (f *FT232H) WaitForPin(pin int, level gpio.Level) error {
+	if pin != 5 {
+		return fmt.Errorf("d2xx: can only wait on D5, not D%d", pin)
+	}
+	cmd := [1]byte{waitLow}
+	if level {
+		cmd[0] = waitHigh
+	}
+	_, err := f.h.Write(cmd[:])
+	return err
+}
+
+// CBusDirection returns the cached direction of C0 to C7.
+//
+// 0 direction means input, 1 means output.
+func (f *FT232H) CBusDirection() byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cbus.direction
+}
+
+// DBusDirection returns the cached direction of D0 to D7.
+//
+// 0 direction means input, 1 means output.
+func (f *FT232H) DBusDirection() byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.dbus.direction
+}
+
+// SetStartupMode sets the interface mode the device starts in, as stored in
+// the EEPROM.
+//
+// mode can be one of:
+//
+// - "uart": the default; ADbus lines are in UART idle state until the port is
+// configured.
+//
+// - "fifo": 245 FIFO; ADbus lines start as tristate. This is the recommended
+// mode when using I²C or SPI.
+//
+// - "fifo_cpu": 245 FIFO CPU target.
+func (f *FT232H) SetStartupMode(mode string) error {
+	var isFifo, isFifoTar uint8
+	switch mode {
+	case "uart":
+	case "fifo":
+		isFifo = 1
+	case "fifo_cpu":
+		isFifoTar = 1
+	default:
+		return fmt.Errorf("ftdi: unknown startup mode %q; use one of \"uart\", \"fifo\" or \"fifo_cpu\"", mode)
+	}
+	var ee EEPROM
+	if err := f.h.ReadEEPROM(&ee); err != nil {
+		return err
+	}
+	e := ee.AsFT232H()
+	if e == nil {
+		return errors.New("ftdi: unexpected EEPROM size")
+	}
+	// The interface modes are mutually exclusive.
+	e.IsFifo = isFifo
+	e.IsFifoTar = isFifoTar
+	e.IsFastSer = 0
+	e.IsFT1248 = 0
+	return f.h.WriteEEPROM(&ee)
+}
+
 // I2C returns an I²C bus over the AD bus.
 //
 // pull can be either gpio.PullUp or gpio.Float. The recommended pull up
@@ -375,6 +886,11 @@ func (f *FT232H) DBusRead() (byte, error) {
 //
 // It uses D0, D1 and D2.
 //
+// The bus returned also implements SetResetOnError(bool) to reset the MPSSE
+// after a failed transaction, ActualSpeed() physic.Frequency to read back the
+// I²C clock resulting from SetSpeed(), and TxContext(ctx context.Context,
+// addr uint16, w, r []byte) error to abort a transaction.
+//
 // D0 is SCL. It must to be pulled up externally.
 //
 // D1 and D2 are used for SDA. D1 is the output using open drain, D2 is the
@@ -400,6 +916,9 @@ func (f *FT232H) I2C(pull gpio.Pull) (i2c.BusCloser, error) {
 	if f.usingSPI {
 		return nil, errors.New("d2xx: already using SPI")
 	}
+	if f.usingMCU {
+		return nil, errors.New("d2xx: already using MCU host bus")
+	}
 	if err := f.i.setupI2C(pull == gpio.PullUp); err != nil {
 		_ = f.i.stopI2C()
 		return nil, err
@@ -407,10 +926,106 @@ func (f *FT232H) I2C(pull gpio.Pull) (i2c.BusCloser, error) {
 	return &f.i, nil
 }
 
+// Status returns the number of bytes waiting in the USB receive queue, e.g.
+// to detect that the host doesn't read the device output fast enough.
+//
+// TODO(maruel): queueOut, the number of bytes pending transmission, requires
+// FT_GetStatus() which periph.io/x/d2xx doesn't expose yet; it is always 0.
+// The FT232H has no temperature sensor or other status register.
+func (f *FT232H) Status() (queueIn, queueOut int, err error) {
+	queueIn, err = f.h.QueueStatus()
+	return queueIn, 0, err
+}
+
+// ResetMPSSE resets the MPSSE command processor and restores the GPIOs to
+// their last known state.
+//
+// It recovers a desynchronized command stream without the full USB device
+// reset done at initialization, which glitches all the pins. The clock speed
+// is not restored; call SetSpeed() or reconnect the I²C bus or SPI port.
+func (f *FT232H) ResetMPSSE() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.resetMPSSELocked()
+}
+
+// resetMPSSELocked is ResetMPSSE() with f.mu held.
+func (f *FT232H) resetMPSSELocked() error {
+	if err := f.h.ResetMPSSE(); err != nil {
+		return err
+	}
+	if err := f.h.MPSSEDBus(f.dbus.direction, f.dbus.value); err != nil {
+		return err
+	}
+	return f.h.MPSSECBus(f.cbus.direction, f.cbus.value)
+}
+
+// DriveCurrent returns the drive current in mA of a pin group as configured
+// in the EEPROM. group is "AD" for D0~D7 or "AC" for C0~C9.
+func (f *FT232H) DriveCurrent(group string) (int, error) {
+	var ee EEPROM
+	if err := f.h.ReadEEPROM(&ee); err != nil {
+		return 0, err
+	}
+	v, err := driveCurrent(&ee, group)
+	if err != nil {
+		return 0, err
+	}
+	return int(*v), nil
+}
+
+// SetDriveCurrent configures in the EEPROM the drive current of a pin group,
+// e.g. to drive longer traces. group is "AD" for D0~D7 or "AC" for C0~C9. mA
+// must be one of 4, 8, 12 or 16.
+func (f *FT232H) SetDriveCurrent(group string, mA int) error {
+	if mA != 4 && mA != 8 && mA != 12 && mA != 16 {
+		return fmt.Errorf("ftdi: invalid drive current %dmA; use 4, 8, 12 or 16", mA)
+	}
+	var ee EEPROM
+	if err := f.h.ReadEEPROM(&ee); err != nil {
+		return err
+	}
+	v, err := driveCurrent(&ee, group)
+	if err != nil {
+		return err
+	}
+	if *v == uint8(mA) {
+		return nil
+	}
+	*v = uint8(mA)
+	return f.h.WriteEEPROM(&ee)
+}
+
+// driveCurrent returns the EEPROM drive current field of the FT232H pin group.
+func driveCurrent(ee *EEPROM, group string) (*uint8, error) {
+	e := ee.AsFT232H()
+	if e == nil {
+		return nil, errors.New("ftdi: unexpected EEPROM size")
+	}
+	switch group {
+	case "AD":
+		return &e.ADDriveCurrent, nil
+	case "AC":
+		return &e.ACDriveCurrent, nil
+	default:
+		return nil, fmt.Errorf("ftdi: unknown pin group %q; use \"AD\" or \"AC\"", group)
+	}
+}
+
+// MaxSPISpeed returns the maximum SPI clock supported by the device. Higher
+// values passed to Connect() are lowered to this value.
+func (f *FT232H) MaxSPISpeed() physic.Frequency {
+	return ft232hMaxSPISpeed
+}
+
 // SPI returns a SPI port over the AD bus.
 //
 // It uses D0, D1, D2 and D3. D0 is the clock, D1 the output (MOSI), D2 is the
 // input (MISO) and D3 is CS line.
+//
+// The spi.Conn returned by Connect() also implements Mode() spi.Mode and
+// MaxSpeed() physic.Frequency to read back the bus configuration, and
+// SetResetOnError(bool) to reset the MPSSE after a failed transaction.
 func (f *FT232H) SPI() (spi.PortCloser, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -420,11 +1035,109 @@ func (f *FT232H) SPI() (spi.PortCloser, error) {
 	if f.usingSPI {
 		return nil, errors.New("d2xx: already using SPI")
 	}
+	if f.usingMCU {
+		return nil, errors.New("d2xx: already using MCU host bus")
+	}
 	// Don't mark it as being used yet. It only become used once Connect() is
 	// called.
 	return &f.s, nil
 }
 
+// ProbeSPIFlash reads the JEDEC ID of a SPI flash chip connected to the SPI
+// port, trying SPI modes 0 and 3 in turn.
+//
+// It returns the 3 bytes JEDEC ID (manufacturer, memory type, capacity) and
+// the first mode that returned a plausible manufacturer byte, that is neither
+// 0x00 nor 0xFF.
+//
+// The SPI port must not be in use.
+func (f *FT232H) ProbeSPIFlash() (jedecID []byte, mode spi.Mode, err error) {
+	p, err := f.SPI()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer p.Close()
+	for _, m := range [...]spi.Mode{spi.Mode0, spi.Mode3} {
+		c, err := p.Connect(physic.MegaHertz, m, 8)
+		if err != nil {
+			return nil, 0, err
+		}
+		// JEDEC Read Identification.
+		w := [4]byte{0x9F}
+		var r [4]byte
+		if err := c.Tx(w[:], r[:]); err != nil {
+			return nil, 0, err
+		}
+		if r[1] != 0x00 && r[1] != 0xFF {
+			return r[1:], m, nil
+		}
+	}
+	return nil, 0, errors.New("d2xx: no SPI flash detected in mode 0 or 3")
+}
+
+// MCURead reads a byte at addr from a peripheral connected via the MCU host
+// bus emulation mode.
+//
+// The first call to MCURead() or MCUWrite() switches the device to MCU host
+// bus emulation, after which I²C and SPI cannot be used until MCUClose() is
+// called.
+func (f *FT232H) MCURead(addr uint16) (byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.setupMCULocked(); err != nil {
+		return 0, err
+	}
+	return f.h.MPSSERegRead(addr)
+}
+
+// MCUWrite writes v at addr to a peripheral connected via the MCU host bus
+// emulation mode.
+//
+// See MCURead() for more details.
+func (f *FT232H) MCUWrite(addr uint16, v byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.setupMCULocked(); err != nil {
+		return err
+	}
+	return f.h.MPSSERegWrite(addr, v)
+}
+
+// MCUClose leaves the MCU host bus emulation mode and switches the device
+// back to MPSSE, so I²C and SPI can be used again. The GPIOs are restored to
+// their last known state.
+func (f *FT232H) MCUClose() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.usingMCU {
+		return nil
+	}
+	if err := f.resetMPSSELocked(); err != nil {
+		return err
+	}
+	f.usingMCU = false
+	return nil
+}
+
+// setupMCULocked switches the device to MCU host bus emulation mode if not
+// already done.
+func (f *FT232H) setupMCULocked() error {
+	if f.usingMCU {
+		return nil
+	}
+	if f.usingI2C {
+		return errors.New("d2xx: already using I²C")
+	}
+	if f.usingSPI {
+		return errors.New("d2xx: already using SPI")
+	}
+	if err := f.h.SetBitMode(0, bitModeMcuHost); err != nil {
+		return err
+	}
+	f.usingMCU = true
+	return nil
+}
+
 //
 
 func newFT232R(g generic) (*FT232R, error) {
@@ -552,6 +1265,33 @@ type FT232R struct {
 	dmask      uint8 // 0 input, 1 output
 	dvalue     uint8
 	cbusnibble uint8 // upper nibble is I/O control, lower nibble is values.
+	chunk      int   // 0 means the default of 64 bytes.
+}
+
+// Halt implements conn.Resource.
+//
+// It aborts the in-flight transfers and sets all the GPIOs as inputs, unless
+// disabled via SetCloseBehavior(). The device is not reset.
+func (f *FT232R) Halt() error {
+	f.h.abort()
+	if f.h.keepPinsOnHalt() {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cbusnibble&0xF0 != 0 {
+		if err := f.h.SetBitMode(0, bitModeCbusBitbang); err != nil {
+			return err
+		}
+		f.cbusnibble = 0
+	}
+	// Always set the DBus mode last, as SetBitMode() switches the mode of the
+	// whole device.
+	if err := f.h.SetBitMode(0, bitModeAsyncBitbang); err != nil {
+		return err
+	}
+	f.dmask = 0
+	return nil
 }
 
 // Header returns the GPIO pins exposed on the chip.
@@ -561,6 +1301,21 @@ func (f *FT232R) Header() []gpio.PinIO {
 	return out
 }
 
+// Summary returns the device type, serial number, bit mode, the buses in use
+// and the clock.
+func (f *FT232R) Summary() string {
+	var buses []string
+	f.mu.Lock()
+	if f.usingSPI {
+		buses = append(buses, "SPI")
+	}
+	if f.usingCBus {
+		buses = append(buses, "CBus")
+	}
+	f.mu.Unlock()
+	return f.summary(buses)
+}
+
 // SetDBusMask sets all D0~D7 input or output mode at once.
 //
 // mask is the input/output pins to use. A bit value of 0 sets the
@@ -577,6 +1332,60 @@ func (f *FT232R) SetDBusMask(mask uint8) error {
 	return f.setDBusMaskLocked(mask)
 }
 
+// SetChunkSize overrides the size of the USB transfers used by Tx() and the
+// SPI port.
+//
+// The FT232R has 128 bytes TX buffer and 256 bytes RX buffer. By default the
+// transfers are chunked into 64 bytes so there's always one chunk in flight
+// while the next one is queued. A smaller size trades speed for reliability on
+// marginal USB links that otherwise overrun. size must be between 1 and 128
+// inclusively; 0 restores the default.
+func (f *FT232R) SetChunkSize(size int) error {
+	if size < 0 || size > 128 {
+		return fmt.Errorf("d2xx: invalid chunk size %d; must be between 1 and 128", size)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.chunk = size
+	return nil
+}
+
+// HighCurrentIO returns true if the I/Os are configured in the EEPROM to
+// drive 3mA instead of 1mA (at 3.3V).
+func (f *FT232R) HighCurrentIO() (bool, error) {
+	var ee EEPROM
+	if err := f.h.ReadEEPROM(&ee); err != nil {
+		return false, err
+	}
+	e := ee.AsFT232R()
+	if e == nil {
+		return false, errors.New("ftdi: unexpected EEPROM size")
+	}
+	return e.IsHighCurrent != 0, nil
+}
+
+// SetHighCurrentIO configures the I/Os in the EEPROM to drive 3mA instead of
+// 1mA (at 3.3V), e.g. to drive a LED directly from a D pin.
+func (f *FT232R) SetHighCurrentIO(high bool) error {
+	var ee EEPROM
+	if err := f.h.ReadEEPROM(&ee); err != nil {
+		return err
+	}
+	e := ee.AsFT232R()
+	if e == nil {
+		return errors.New("ftdi: unexpected EEPROM size")
+	}
+	v := uint8(0)
+	if high {
+		v = 1
+	}
+	if e.IsHighCurrent == v {
+		return nil
+	}
+	e.IsHighCurrent = v
+	return f.h.WriteEEPROM(&ee)
+}
+
 // Tx does synchronized read-then-write on all the D0~D7 GPIOs.
 //
 // SetSpeed() determines the pace at which the I/O is done.
@@ -607,10 +1416,59 @@ func (f *FT232R) Tx(w, r []byte) error {
 	return f.txLocked(w, r)
 }
 
+// Capture samples all the D0~D7 GPIOs n times, at the pace set by SetSpeed().
+//
+// The outputs are kept at their current value while sampling. Combined with
+// Replay(), this turns the FT232R into a simple 8 channels logic analyzer and
+// pattern generator.
+func (f *FT232R) Capture(n int) ([]byte, error) {
+	if n <= 0 {
+		return nil, errors.New("d2xx: the number of samples must be positive")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.usingSPI {
+		return nil, errors.New("d2xx: already using SPI")
+	}
+	r := make([]byte, n)
+	if err := f.txLocked(nil, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Replay drives the D0~D7 GPIOs with samples, one byte per clock, at the pace
+// set by SetSpeed(), e.g. to play back a waveform recorded with Capture().
+//
+// Only the bits set as output by SetDBusMask() are driven.
+func (f *FT232R) Replay(samples []byte) error {
+	if len(samples) == 0 {
+		return errors.New("d2xx: no samples to replay")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.usingSPI {
+		return errors.New("d2xx: already using SPI")
+	}
+	return f.txLocked(samples, nil)
+}
+
+// MaxSPISpeed returns the maximum SPI clock supported by the device. Higher
+// values passed to Connect() are lowered to this value.
+func (f *FT232R) MaxSPISpeed() physic.Frequency {
+	return ft232rMaxSpeed / 2
+}
+
 // SPI returns a SPI port over the first 4 pins.
 //
 // It uses D0(TX), D1(RX), D2(RTS) and D3(CTS). D2(RTS) is the clock, D0(TX)
 // the output (MOSI), D1(RX) is the input (MISO) and D3(CTS) is CS line.
+//
+// With spi.HalfDuplex, D0(TX) is the shared data line of a 3 wire device and
+// D1(RX) is unused. D0 is switched to an input while reading.
+//
+// The spi.Conn returned by Connect() also implements Mode() spi.Mode and
+// MaxSpeed() physic.Frequency to read back the bus configuration.
 func (f *FT232R) SPI() (spi.PortCloser, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -642,7 +1500,16 @@ func (f *FT232R) txLocked(w, r []byte) error {
 	// pipelining and removes the risk of buffer overrun. This is important
 	// otherwise there's huge gaps due to the USB transmit overhead.
 	// TODO(maruel): Determine what's optimal via experimentation.
+	// SetChunkSize() can override it.
 	chunk := 64
+	if f.chunk != 0 {
+		chunk = f.chunk
+	}
+	// The first write fills the buffer.
+	first := 2 * chunk
+	if first > 128 {
+		first = 128
+	}
 	var scratch [128]byte
 	if len(w) == 0 {
 		// Read only.
@@ -665,18 +1532,17 @@ func (f *FT232R) txLocked(w, r []byte) error {
 		}
 	} else if len(r) == 0 {
 		// Write only.
-		// The first write is 128 bytes to fill the buffer.
-		chunk = 128
+		// The first write is twice the chunk size to fill the buffer.
+		c := first
 		for len(w) != 0 {
-			c := len(w)
-			if c > chunk {
-				c = chunk
+			if c > len(w) {
+				c = len(w)
 			}
 			if _, err := f.h.Write(w[:c]); err != nil {
 				return err
 			}
 			w = w[c:]
-			chunk = 64
+			c = chunk
 		}
 		/*
 			// Let the USB drive pace it.
@@ -687,17 +1553,15 @@ func (f *FT232R) txLocked(w, r []byte) error {
 	} else {
 		// R/W.
 		// Always write one 'w' ahead.
-		// The first write is 128 bytes to fill the buffer.
-		chunk = 128
+		// The first write is twice the chunk size to fill the buffer.
 		cw := len(w)
-		if cw > chunk {
-			cw = chunk
+		if cw > first {
+			cw = first
 		}
 		if _, err := f.h.Write(w[:cw]); err != nil {
 			return err
 		}
 		w = w[cw:]
-		chunk = 64
 		for len(r) != 0 {
 			// Read then write.
 			cr := len(r)
@@ -887,4 +1751,171 @@ func (f *FT232R) cBusGPIOOut(n int, l gpio.Level) error {
 
 //
 
+func newFTX(g generic) (*FTX, error) {
+	f := &FTX{
+		generic: g,
+		cbus:    [...]cbusPin{{num: 0, p: gpio.PullUp}, {num: 1, p: gpio.PullUp}, {num: 2, p: gpio.PullUp}, {num: 3, p: gpio.PullUp}},
+	}
+	f.u.f = f
+	for i := range f.cbus {
+		f.cbus[i].n = f.name + ".C" + strconv.Itoa(i)
+		f.cbus[i].bus = f
+		f.hdr[i] = &f.cbus[i]
+	}
+	f.C0 = f.hdr[0]
+	f.C1 = f.hdr[1]
+	f.C2 = f.hdr[2]
+	f.C3 = f.hdr[3]
+
+	// Unlike the FT232R, flow control is not enabled, since most FT-X cables
+	// only wire RX and TX.
+	if err := f.h.Flush(); err != nil {
+		return nil, err
+	}
+	// Set all CBus pins as input.
+	if err := f.h.SetBitMode(0, bitModeCbusBitbang); err != nil {
+		return nil, err
+	}
+	// And read their value.
+	var err error
+	if f.cbusnibble, err = f.h.GetBitMode(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// FTX represents a FT-X series device, like the FT230X, FT231X or FT234XD.
+//
+// It exposes its UART and C0~C3 as GPIOs.
+//
+// The CBus pins must be set to "I/O Mode" (CBitBangI/O) in the EEPROM to be
+// usable as GPIOs.
+type FTX struct {
+	generic
+
+	// The CBus pins are slower to use, but can drive an high load, like a LED.
+	C0 gpio.PinIO
+	C1 gpio.PinIO
+	C2 gpio.PinIO
+	C3 gpio.PinIO
+
+	cbus [4]cbusPin
+	hdr  [4]gpio.PinIO
+
+	// Mutable.
+	mu         sync.Mutex
+	usingUART  bool
+	u          uartPort
+	cbusnibble uint8 // upper nibble is I/O control, lower nibble is values.
+}
+
+// Halt implements conn.Resource.
+//
+// It aborts the in-flight transfers and sets all the GPIOs as inputs, unless
+// disabled via SetCloseBehavior(). The device is not reset.
+func (f *FTX) Halt() error {
+	f.h.abort()
+	if f.h.keepPinsOnHalt() {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.h.SetBitMode(0, bitModeCbusBitbang); err != nil {
+		return err
+	}
+	f.cbusnibble = 0
+	return nil
+}
+
+// Header returns the GPIO pins exposed on the chip.
+func (f *FTX) Header() []gpio.PinIO {
+	out := make([]gpio.PinIO, len(f.hdr))
+	copy(out, f.hdr[:])
+	return out
+}
+
+// UART returns the UART port.
+//
+// The port can only be opened once at a time.
+func (f *FTX) UART() (uart.PortCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.usingUART {
+		return nil, errors.New("d2xx: already using UART")
+	}
+	f.usingUART = true
+	return &f.u, nil
+}
+
+// cBusGPIOFunc implements cBusGPIO.
+func (f *FTX) cBusGPIOFunc(n int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fmask := uint8(0x10 << uint(n))
+	vmask := uint8(1 << uint(n))
+	if f.cbusnibble&fmask != 0 {
+		return "Out/" + gpio.Level(f.cbusnibble&vmask != 0).String()
+	}
+	return "In/" + f.cBusReadLocked(n).String()
+}
+
+// cBusGPIOIn implements cBusGPIO.
+func (f *FTX) cBusGPIOIn(n int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fmask := uint8(0x10 << uint(n))
+	if f.cbusnibble&fmask == 0 {
+		// Already input.
+		return nil
+	}
+	v := f.cbusnibble &^ fmask
+	if err := f.h.SetBitMode(v, bitModeCbusBitbang); err != nil {
+		return err
+	}
+	f.cbusnibble = v
+	return nil
+}
+
+// cBusGPIORead implements cBusGPIO.
+func (f *FTX) cBusGPIORead(n int) gpio.Level {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cBusReadLocked(n)
+}
+
+func (f *FTX) cBusReadLocked(n int) gpio.Level {
+	v, err := f.h.GetBitMode()
+	if err != nil {
+		return gpio.Low
+	}
+	f.cbusnibble = v
+	vmask := uint8(1 << uint(n))
+	return f.cbusnibble&vmask != 0
+}
+
+// cBusGPIOOut implements cBusGPIO.
+func (f *FTX) cBusGPIOOut(n int, l gpio.Level) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fmask := uint8(0x10 << uint(n))
+	vmask := uint8(1 << uint(n))
+	v := f.cbusnibble | fmask
+	if l {
+		v |= vmask
+	} else {
+		v &^= vmask
+	}
+	if f.cbusnibble == v {
+		// Was already in the right mode.
+		return nil
+	}
+	if err := f.h.SetBitMode(v, bitModeCbusBitbang); err != nil {
+		return err
+	}
+	f.cbusnibble = v
+	return nil
+}
+
+//
+
 var _ conn.Resource = Dev(nil)
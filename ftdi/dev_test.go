@@ -0,0 +1,514 @@
+// Copyright 2026 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package ftdi
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/conn/v3/spi"
+	"periph.io/x/conn/v3/uart"
+	"periph.io/x/d2xx/d2xxtest"
+)
+
+func TestFT232R_Halt(t *testing.T) {
+	f := newFakeFT232R(t)
+	f.dmask = 0x0F
+	f.cbusnibble = 0x11
+	if err := f.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if f.dmask != 0 || f.cbusnibble != 0 {
+		t.Fatal(f.dmask, f.cbusnibble)
+	}
+}
+
+func TestFT232H_Direction(t *testing.T) {
+	f, _ := newFakeFT232H(t)
+	if d := f.DBusDirection(); d != 0 {
+		t.Fatal(d)
+	}
+	if err := f.D5.Out(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.C2.Out(false); err != nil {
+		t.Fatal(err)
+	}
+	if d := f.DBusDirection(); d != 0x20 {
+		t.Fatal(d)
+	}
+	if d := f.CBusDirection(); d != 0x04 {
+		t.Fatal(d)
+	}
+}
+
+func TestFT232H_SetStartupMode(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	fh.E.Raw = make([]byte, DevTypeFT232H.EEPROMSize())
+	fh.E.Raw[0] = byte(DevTypeFT232H)
+	if err := f.SetStartupMode("fifo"); err != nil {
+		t.Fatal(err)
+	}
+	ee := EEPROM{Raw: fh.E.Raw}
+	if e := ee.AsFT232H(); e.IsFifo != 1 || e.IsFifoTar != 0 {
+		t.Fatalf("%+v", e)
+	}
+	if err := f.SetStartupMode("uart"); err != nil {
+		t.Fatal(err)
+	}
+	ee = EEPROM{Raw: fh.E.Raw}
+	if e := ee.AsFT232H(); e.IsFifo != 0 || e.IsFifoTar != 0 {
+		t.Fatalf("%+v", e)
+	}
+	if f.SetStartupMode("245") == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestFT232H_SetClockPhase(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	if err := f.SetClockPhase(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetClockPhase(false); err != nil {
+		t.Fatal(err)
+	}
+	if string(fh.W) != string([]byte{clock3Phase, clock2Phase}) {
+		t.Fatalf("%#v", fh.W)
+	}
+}
+
+func TestFT232R_SetChunkSize(t *testing.T) {
+	fh := &fakeHandle{Fake: d2xxtest.Fake{Data: [][]byte{{}, {0}}}}
+	f, err := newFT232R(generic{h: &handle{h: fh, t: DevTypeFT232R}, name: "FT232R"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.SetChunkSize(129) == nil || f.SetChunkSize(-1) == nil {
+		t.Fatal("expected error")
+	}
+	if err := f.SetChunkSize(16); err != nil {
+		t.Fatal(err)
+	}
+	fh.N = nil
+	if err := f.Tx(make([]byte, 100), nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fh.N, []int{32, 16, 16, 16, 16, 4}) {
+		t.Fatal(fh.N)
+	}
+	if err := f.SetChunkSize(0); err != nil {
+		t.Fatal(err)
+	}
+	fh.N = nil
+	if err := f.Tx(make([]byte, 200), nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fh.N, []int{128, 64, 8}) {
+		t.Fatal(fh.N)
+	}
+}
+
+func TestGeneric_Acquire(t *testing.T) {
+	var d Dev = newFakeFT232R(t)
+	d.Acquire()
+	acquired := make(chan struct{})
+	go func() {
+		d.Acquire()
+		close(acquired)
+		d.Release()
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Acquire() didn't block")
+	case <-time.After(10 * time.Millisecond):
+	}
+	d.Release()
+	<-acquired
+}
+
+func TestGeneric_EnableRS485(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	fh.E.Raw = make([]byte, DevTypeFT232H.EEPROMSize())
+	fh.E.Raw[0] = byte(DevTypeFT232H)
+	if f.EnableRS485(7) == nil || f.EnableRS485(10) == nil {
+		t.Fatal("expected error")
+	}
+	if err := f.EnableRS485(9); err != nil {
+		t.Fatal(err)
+	}
+	ee := EEPROM{Raw: fh.E.Raw}
+	if e := ee.AsFT232H(); e.Cbus9 != FT232hCBusTxdEnable || e.Cbus8 != FT232hCBusTristatePullUp {
+		t.Fatalf("%+v", e)
+	}
+}
+
+func TestGeneric_SetPowerConfig(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	fh.E.Raw = make([]byte, DevTypeFT232H.EEPROMSize())
+	fh.E.Raw[0] = byte(DevTypeFT232H)
+	if f.SetPowerConfig(false, 0, false) == nil || f.SetPowerConfig(false, 501, false) == nil {
+		t.Fatal("expected error")
+	}
+	if err := f.SetPowerConfig(true, 100, true); err != nil {
+		t.Fatal(err)
+	}
+	ee := EEPROM{Raw: fh.E.Raw}
+	if h := ee.AsHeader(); h.SelfPowered != 1 || h.MaxPower != 100 || h.RemoteWakeup != 1 || h.DeviceType != DevTypeFT232H {
+		t.Fatalf("%+v", h)
+	}
+}
+
+func TestFT232H_WaitForPin(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	if f.WaitForPin(4, gpio.High) == nil {
+		t.Fatal("expected error")
+	}
+	if err := f.WaitForPin(5, gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.WaitForPin(5, gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if string(fh.W) != string([]byte{waitHigh, waitLow}) {
+		t.Fatalf("%#v", fh.W)
+	}
+}
+
+func TestFT232R_SetCloseBehavior(t *testing.T) {
+	f := newFakeFT232R(t)
+	f.SetCloseBehavior(false)
+	f.dmask = 0x0F
+	if err := f.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if f.dmask != 0x0F {
+		t.Fatal(f.dmask)
+	}
+	f.SetCloseBehavior(true)
+	if err := f.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if f.dmask != 0 {
+		t.Fatal(f.dmask)
+	}
+}
+
+func TestFT232H_MCU(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	if err := f.MCUWrite(0x1234, 0x56); err != nil {
+		t.Fatal(err)
+	}
+	if !f.usingMCU {
+		t.Fatal("expected MCU host bus mode")
+	}
+	if _, err := f.I2C(gpio.PullUp); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := f.SPI(); err == nil {
+		t.Fatal("expected error")
+	}
+	// Switch back to MPSSE.
+	fh.Data = [][]byte{{}, {0xFA, 0xAA}, {0xFA, 0xAB}}
+	if err := f.MCUClose(); err != nil {
+		t.Fatal(err)
+	}
+	if f.usingMCU {
+		t.Fatal("expected MPSSE mode")
+	}
+	if _, err := f.SPI(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGeneric_DecodeEEPROM(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	fh.E.Raw = make([]byte, DevTypeFT232H.EEPROMSize())
+	fh.E.Raw[0] = byte(DevTypeFT232H)
+	v, err := f.DecodeEEPROM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := v.(*EEPROMFT232H); !ok || e.DeviceType != DevTypeFT232H {
+		t.Fatalf("%#v", v)
+	}
+}
+
+func TestFT232H_ProbeSPIFlash(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	// Mode 0 reads nothing, mode 3 reads a Winbond W25Q64.
+	fh.Data = [][]byte{{0xFF, 0xFF, 0xFF, 0xFF}, {0xFF, 0xEF, 0x40, 0x17}}
+	id, m, err := f.ProbeSPIFlash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != spi.Mode3 || !bytes.Equal(id, []byte{0xEF, 0x40, 0x17}) {
+		t.Fatal(m, id)
+	}
+	if f.usingSPI {
+		t.Fatal("SPI port must be closed")
+	}
+}
+
+func TestGeneric_ReadEEPROMWord(t *testing.T) {
+	f, _ := newFakeFT232H(t)
+	// The raw EEPROM content is not the physical words, so it must not be
+	// used.
+	if _, err := f.ReadEEPROMWord(2); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := f.WriteEEPROMWord(0x100, 0); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestFTX(t *testing.T) {
+	fh := &fakeHandle{Fake: d2xxtest.Fake{Data: [][]byte{{}}}}
+	f, err := newFTX(generic{h: &handle{h: fh, t: DevTypeFTXSeries}, name: "FTX"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := f.C2.Name(); n != "FTX.C2" {
+		t.Fatal(n)
+	}
+	if err := f.C0.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if f.cbusnibble != 0x11 {
+		t.Fatalf("%#x", f.cbusnibble)
+	}
+	u, err := f.UART()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.UART(); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := u.Connect(115200*physic.Hertz, uart.One, uart.Even, uart.NoFlow, 8); err == nil {
+		t.Fatal("expected error")
+	}
+	c, err := u.Connect(115200*physic.Hertz, uart.One, uart.NoParity, uart.NoFlow, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Tx([]byte{1, 2}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if string(fh.W) != "\x01\x02" {
+		t.Fatalf("%#v", fh.W)
+	}
+	if err := u.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Halt(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFT232R_HighCurrentIO(t *testing.T) {
+	fh := &fakeHandle{Fake: d2xxtest.Fake{Data: [][]byte{{}, {0}}}}
+	f, err := newFT232R(generic{h: &handle{h: fh, t: DevTypeFT232R}, name: "FT232R"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh.E.Raw = make([]byte, DevTypeFT232R.EEPROMSize())
+	fh.E.Raw[0] = byte(DevTypeFT232R)
+	if v, err := f.HighCurrentIO(); err != nil || v {
+		t.Fatal(v, err)
+	}
+	if err := f.SetHighCurrentIO(true); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := f.HighCurrentIO(); err != nil || !v {
+		t.Fatal(v, err)
+	}
+	if fh.E.Raw[0x10] != 1 {
+		t.Fatalf("%#v", fh.E.Raw)
+	}
+}
+
+func TestMaxSPISpeed(t *testing.T) {
+	h, _ := newFakeFT232H(t)
+	if s := h.MaxSPISpeed(); s != 30*physic.MegaHertz {
+		t.Fatal(s)
+	}
+	r := newFakeFT232R(t)
+	if s := r.MaxSPISpeed(); s != 1500*physic.KiloHertz {
+		t.Fatal(s)
+	}
+}
+
+func TestFT232H_ResetMPSSE(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	f.dbus.direction = 0x0F
+	f.dbus.value = 0x05
+	fh.Data = [][]byte{{}, {0xFA, 0xAA}, {0xFA, 0xAB}}
+	fh.W = nil
+	if err := f.ResetMPSSE(); err != nil {
+		t.Fatal(err)
+	}
+	// The verification commands, then the GPIOs are restored.
+	want := []byte{0xAA, flush, 0xAB, flush, gpioSetD, 0x05, 0x0F, gpioSetC, 0, 0}
+	if !bytes.Equal(fh.W, want) {
+		t.Fatalf("%#v", fh.W)
+	}
+	if f.ResetMPSSE() == nil {
+		t.Fatal("expected verification failure")
+	}
+}
+
+func TestFT232H_OutOpenDrain(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	p, ok := f.D4.(interface{ OutOpenDrain(l gpio.Level) error })
+	if !ok {
+		t.Fatalf("%T", f.D4)
+	}
+	if err := f.D4.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.OutOpenDrain(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.OutOpenDrain(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{gpioSetD, 0x10, 0x10, gpioSetD, 0x00, 0x10, gpioSetD, 0x00, 0x00}
+	if !bytes.Equal(fh.W, want) {
+		t.Fatalf("%#v", fh.W)
+	}
+}
+
+func TestFT232H_DriveCurrent(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	fh.E.Raw = make([]byte, DevTypeFT232H.EEPROMSize())
+	fh.E.Raw[0] = byte(DevTypeFT232H)
+	fh.E.Raw[0x12] = 4
+	fh.E.Raw[0x15] = 4
+	if f.SetDriveCurrent("AD", 10) == nil {
+		t.Fatal("expected error")
+	}
+	if f.SetDriveCurrent("AB", 8) == nil {
+		t.Fatal("expected error")
+	}
+	if err := f.SetDriveCurrent("AD", 12); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := f.DriveCurrent("AD"); err != nil || v != 12 {
+		t.Fatal(v, err)
+	}
+	if v, err := f.DriveCurrent("AC"); err != nil || v != 4 {
+		t.Fatal(v, err)
+	}
+	if fh.E.Raw[0x15] != 12 || fh.E.Raw[0x12] != 4 {
+		t.Fatalf("%#v", fh.E.Raw)
+	}
+}
+
+func TestFT232R_CaptureReplay(t *testing.T) {
+	fh := &fakeHandle{Fake: d2xxtest.Fake{Data: [][]byte{{}, {0}}}}
+	f, err := newFT232R(generic{h: &handle{h: fh, t: DevTypeFT232R}, name: "FT232R"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Capture(0); err == nil {
+		t.Fatal("expected error")
+	}
+	if f.Replay(nil) == nil {
+		t.Fatal("expected error")
+	}
+	want := []byte{0x01, 0x03, 0x07, 0x0F}
+	fh.Data = [][]byte{append([]byte(nil), want...)}
+	fh.W = nil
+	got, err := f.Capture(len(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Capture() = %x", got)
+	}
+	// The outputs are kept steady while sampling.
+	if !bytes.Equal(fh.W, make([]byte, len(want))) {
+		t.Fatalf("unexpected writes %x", fh.W)
+	}
+	fh.W = nil
+	if err := f.Replay(got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fh.W, want) {
+		t.Fatalf("Replay() wrote %x", fh.W)
+	}
+}
+
+func TestGeneric_ReadUserAreaChecked(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	if _, err := f.ReadUserAreaChecked(); err == nil {
+		t.Fatal("expected error on uninitialized EEPROM")
+	}
+	fh.UA = make([]byte, 16)
+	// An erased user area is not valid empty data.
+	if _, err := f.ReadUserAreaChecked(); err == nil {
+		t.Fatal("expected error on erased user area")
+	}
+	if err := f.WriteUserAreaChecked(make([]byte, 10)); err == nil {
+		t.Fatal("expected error, data larger than the user area")
+	}
+	want := []byte("calib")
+	if err := f.WriteUserAreaChecked(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.ReadUserAreaChecked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("ReadUserAreaChecked() = %q", got)
+	}
+	// Simulate a corrupted write.
+	fh.UA[userAreaHeader+1] ^= 0xFF
+	if _, err := f.ReadUserAreaChecked(); err == nil {
+		t.Fatal("expected CRC error")
+	}
+	fh.UA[1] = 0xFF
+	if _, err := f.ReadUserAreaChecked(); err == nil {
+		t.Fatal("expected length error")
+	}
+	fh.UA[0] = 0
+	if _, err := f.ReadUserAreaChecked(); err == nil {
+		t.Fatal("expected header error")
+	}
+}
+
+func TestFT232H_Status(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	fh.Data = [][]byte{{1, 2, 3}}
+	in, out, err := f.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in != 3 || out != 0 {
+		t.Fatalf("Status() = %d, %d", in, out)
+	}
+}
+
+func TestFT232H_Summary(t *testing.T) {
+	f, _ := newFakeFT232H(t)
+	f.serial = "FT123"
+	if _, err := f.I2C(gpio.Float); err != nil {
+		t.Fatal(err)
+	}
+	want := "FT232H(type=FT232H, serial=FT123, mode=MPSSE, bus=I²C, clock=400kHz)"
+	if s := f.Summary(); s != want {
+		t.Fatalf("Summary() = %q; want %q", s, want)
+	}
+	b := &broken{name: "broken#0", err: errors.New("oops")}
+	if s := b.Summary(); s != "broken#0(broken: oops)" {
+		t.Fatal(s)
+	}
+}
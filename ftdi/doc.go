@@ -4,11 +4,17 @@
 
 // Package ftdi implements support for popular FTDI devices.
 //
-// The supported devices (FT232h/FT232r) implement support for various
+// The supported devices (FT232h/FT232r/FT-X series) implement support for various
 // protocols like the GPIO, I²C, SPI, UART, JTAG.
 //
 // Use build tag periph_host_ftdi_debug to enable verbose debugging.
 //
+// # EEPROM
+//
+// The methods changing a setting stored in the EEPROM, like EnableRS485(),
+// SetStartupMode() or SetDriveCurrent(), write the EEPROM back immediately.
+// The device only uses the new setting once it is power cycled.
+//
 // # More details
 //
 // See https://periph.io/device/ftdi/ for more details, and how to configure
@@ -19,4 +25,6 @@
 // http://www.ftdichip.com/Support/Documents/DataSheets/ICs/DS_FT232R.pdf
 //
 // http://www.ftdichip.com/Support/Documents/DataSheets/ICs/DS_FT232H.pdf
+//
+// https://ftdichip.com/wp-content/uploads/2020/08/DS_FT231X.pdf
 package ftdi
@@ -5,8 +5,11 @@
 package ftdi
 
 import (
+	"errors"
+	"slices"
 	"strconv"
 	"sync"
+	"time"
 
 	"periph.io/x/conn/v3/driver/driverreg"
 	"periph.io/x/conn/v3/gpio"
@@ -16,6 +19,7 @@ import (
 	"periph.io/x/conn/v3/pin"
 	"periph.io/x/conn/v3/pin/pinreg"
 	"periph.io/x/conn/v3/spi/spireg"
+	"periph.io/x/conn/v3/uart/uartreg"
 	"periph.io/x/d2xx"
 )
 
@@ -28,48 +32,228 @@ func All() []Dev {
 	return out
 }
 
-// rescan rescans the USB bus for new or disconnected devices.
-func rescan() error {
+// PinByName returns a GPIO pin by name across all the connected FTDI devices.
+//
+// The name is the fully qualified name of the pin, e.g. "FT232H.D0" or
+// "FT232H(1).C3" when multiple devices are connected. Unlike gpioreg.ByName(),
+// it doesn't rely on the short aliases that are only registered when a single
+// device is present.
+//
+// Returns nil if not found.
+func PinByName(name string) gpio.PinIO {
 	drv.mu.Lock()
 	defer drv.mu.Unlock()
-	e := d2xx.Rescan()
-	// TODO(maruel): Reenumerate the devices.
-	if e != 0 {
-		return toErr("Rescan", e)
+	for _, d := range drv.all {
+		for _, p := range d.Header() {
+			if p.Name() == name {
+				return p
+			}
+		}
 	}
 	return nil
 }
 
+// CloseAll closes all the devices returned by All().
+func CloseAll() error {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	return drv.closeAllLocked()
+}
+
+// Rescan rescans the USB bus for new or disconnected devices.
+//
+// The devices are identified by their type and the serial number read from
+// their EEPROM. The devices previously returned by All() that are still
+// connected are kept as-is, so the references held by the caller stay valid.
+// The disconnected devices are closed and the new ones are opened. Call All()
+// to get the new devices.
+//
+// A device without a serial number can't be told apart from another one of
+// the same type, so it is closed and opened again. The devices that failed to
+// initialize are retried.
+func Rescan() error {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	var errs []error
+	kept := map[string]bool{}
+	for _, dev := range slices.Clone(drv.all) {
+		if h, ok := dev.(interface{ getHandle() *handle }); ok {
+			// Reading the EEPROM fails once the device is disconnected.
+			if k := devKey(h.getHandle()); k != "" && !kept[k] {
+				kept[k] = true
+				continue
+			}
+		}
+		errs = append(errs, drv.closeLocked(dev))
+	}
+	if e := drv.d2xxRescan(); e != 0 {
+		return errors.Join(append(errs, toErr("Rescan", e))...)
+	}
+	num, err := drv.numDevices()
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	// The devices kept can't be opened a second time.
+	held := len(kept)
+	alias := held == 0 && num == 1
+	var failed []int
+	var failedErrs []error
+	for i := 0; i < num; i++ {
+		h, err := openHandle(drv.d2xxOpen, i)
+		if err != nil {
+			failed = append(failed, i)
+			failedErrs = append(failedErrs, err)
+			continue
+		}
+		if kept[devKey(h)] {
+			// The platform permitted to open a kept device again.
+			held--
+			errs = append(errs, h.Close())
+			continue
+		}
+		errs = append(errs, drv.addLocked(i, h, nil, alias))
+	}
+	// The kept devices account for held of the failures, the other ones are
+	// new devices that are broken.
+	// TODO(maruel): Use FT_GetDeviceInfoList() once periph.io/x/d2xx exposes
+	// it, to know which index is held by which device.
+	for j := max(held, 0); j < len(failed); j++ {
+		errs = append(errs, drv.addLocked(failed[j], nil, failedErrs[j], alias))
+	}
+	return errors.Join(errs...)
+}
+
+// SetOpenRetries sets how many times the initialization of a device is
+// retried after resetting it, and the delay before each retry.
 //
+// This helps with USB hubs that enumerate slowly, where the device would
+// otherwise be reported as broken. It takes effect on the next driver
+// initialization or Rescan(). The default is a single retry without delay.
+func SetOpenRetries(n int, backoff time.Duration) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	drv.retries = max(n, 0)
+	drv.backoff = backoff
+}
 
-// open opens a FTDI device.
+// ListDescriptors returns the USB descriptor information and the EEPROM
+// strings of all the connected devices, including the ones that failed to
+// initialize.
 //
-// Must be called with mu held.
-func open(opener func(i int) (d2xx.Handle, d2xx.Err), i int) (Dev, error) {
-	h, err := openHandle(opener, i)
+// The devices already returned by All() are queried through their existing
+// handle. The other ones are opened only for the time needed to read their
+// descriptor; their mode is not changed, so their pins do not glitch. A
+// device held by another process or claimed by a kernel driver can't be
+// queried and is reported with Opened false.
+//
+// TODO(maruel): Use FT_GetDeviceInfoList() once periph.io/x/d2xx exposes it,
+// so the devices do not have to be opened at all.
+func ListDescriptors() ([]Info, error) {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+	num, err := drv.numDevices()
 	if err != nil {
 		return nil, err
 	}
-	if err := h.Init(); err != nil {
-		// setupCommon() takes the device in its previous state. It could be in an
-		// unexpected state, so try resetting it first.
-		if err := h.Reset(); err != nil {
-			_ = h.Close()
-			return nil, err
+	out := make([]Info, num)
+	for i := range out {
+		if h := drv.handleLocked(i); h != nil {
+			out[i] = readDescriptor(h)
+			continue
 		}
-		if err := h.Init(); err != nil {
-			_ = h.Close()
-			return nil, err
+		h, err := openHandle(drv.d2xxOpen, i)
+		if err != nil {
+			continue
+		}
+		out[i] = readDescriptor(h)
+		_ = h.Close()
+	}
+	return out, nil
+}
+
+//
+
+// open initializes a FTDI device opened by openHandle().
+//
+// Must be called with mu held.
+func open(h *handle, i int) (Dev, error) {
+	d, err := initDev(h, i)
+	if err != nil {
+		// The USB descriptor was retrieved, keep it for diagnostic.
+		return nil, &openError{err: err, t: h.t, venID: h.venID, devID: h.devID}
+	}
+	return d, nil
+}
+
+// openError is returned by open() when the device could be opened but not
+// initialized. It keeps the USB descriptor information.
+type openError struct {
+	err   error
+	t     DevType
+	venID uint16
+	devID uint16
+}
+
+func (o *openError) Error() string {
+	return o.err.Error()
+}
+
+func (o *openError) Unwrap() error {
+	return o.err
+}
+
+// readDescriptor returns the USB descriptor information and the EEPROM
+// strings of an opened device.
+func readDescriptor(h *handle) Info {
+	i := Info{Opened: true, Type: h.t.String(), VenID: h.venID, DevID: h.devID}
+	var ee EEPROM
+	if err := h.ReadEEPROM(&ee); err == nil {
+		i.Serial = ee.Serial
+		i.Desc = ee.Desc
+	}
+	return i
+}
+
+// devKey returns the identity of an opened device, made of its type and its
+// serial number. Returns "" if the EEPROM can't be read or has no serial
+// number.
+func devKey(h *handle) string {
+	i := readDescriptor(h)
+	if i.Serial == "" {
+		return ""
+	}
+	return i.Type + "/" + i.Serial
+}
+
+// initDev initializes an opened device. The handle is closed on failure.
+//
+// Must be called with drv.mu held.
+func initDev(h *handle, i int) (Dev, error) {
+	err := h.Init()
+	// setupCommon() takes the device in its previous state. It could be in an
+	// unexpected state, so try resetting it first.
+	for r := 0; err != nil && r < drv.retries; r++ {
+		time.Sleep(drv.backoff)
+		if err = h.Reset(); err == nil {
+			err = h.Init()
 		}
-		// The second attempt worked.
 	}
-	// Makes a copy of the handle.
-	g := generic{index: i, h: h, name: h.t.String()}
+	if err != nil {
+		_ = h.Close()
+		return nil, err
+	}
+	// Makes a copy of the handle. The serial number is cached so Summary()
+	// doesn't read the EEPROM each time.
+	g := generic{index: i, h: h, name: h.t.String(), serial: readDescriptor(h).Serial}
 	if i > 0 {
 		// When more than one device is present, add "(index)" suffix.
 		// TODO(maruel): Using the serial number would be nicer than a number.
 		g.name += "(" + strconv.Itoa(i) + ")"
 	}
+	// A device kept by Rescan() may already use this name.
+	for n := i + 1; drv.nameUsedLocked(g.name); n++ {
+		g.name = h.t.String() + "(" + strconv.Itoa(n) + ")"
+	}
 	// Makes a copy of the generic instance.
 	switch g.h.t {
 	case DevTypeFT232H:
@@ -93,7 +277,18 @@ func open(opener func(i int) (d2xx.Handle, d2xx.Err), i int) (Dev, error) {
 			return nil, err
 		}
 		return f, nil
+	case DevTypeFTXSeries:
+		f, err := newFTX(g)
+		if err != nil {
+			_ = h.Close()
+			return nil, err
+		}
+		return f, nil
 	default:
+		// TODO(maruel): DevTypeFT4222H0~DevTypeFT4222H3 do not use the MPSSE
+		// engine; their SPI and I²C controllers are only reachable via
+		// LibFT4222, which is not wrapped by periph.io/x/d2xx. Until it is, they
+		// are exposed as generic devices.
 		return &g, nil
 	}
 }
@@ -142,17 +337,54 @@ func registerDev(d Dev, multi bool) error {
 		}
 		// TODO(maruel): UART
 	case *FT232R:
-		// TODO(maruel): SPI, UART
+		if err := spireg.Register(name, nil, -1, t.SPI); err != nil {
+			return err
+		}
+		// TODO(maruel): UART
+	case *FTX:
+		if err := uartreg.Register(name, nil, -1, t.UART); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// unregisterDev undoes registerDev.
+func unregisterDev(d Dev, multi bool) error {
+	name := d.String()
+	var errs []error
+	switch d.(type) {
+	case *FT232H:
+		errs = append(errs, i2creg.Unregister(name), spireg.Unregister(name))
+	case *FT232R:
+		errs = append(errs, spireg.Unregister(name))
+	case *FTX:
+		errs = append(errs, uartreg.Unregister(name))
+	}
+	errs = append(errs, pinreg.Unregister(name))
+	hdr := d.Header()
+	if !multi {
+		prefix := len(name) + 1
+		for _, p := range hdr {
+			errs = append(errs, gpioreg.Unregister(p.Name()[prefix:]))
+		}
+	}
+	for _, p := range hdr {
+		errs = append(errs, gpioreg.Unregister(p.Name()))
+	}
+	return errors.Join(errs...)
+}
+
 // driver implements driver.Impl.
 type driver struct {
 	mu         sync.Mutex
 	all        []Dev
+	aliased    Dev // The device whose pins are registered with short aliases.
 	d2xxOpen   func(i int) (d2xx.Handle, d2xx.Err)
+	d2xxRescan func() d2xx.Err
 	numDevices func() (int, error)
+	retries    int           // Number of Reset() and Init() retries.
+	backoff    time.Duration // Delay before each retry.
 }
 
 func (d *driver) String() string {
@@ -168,44 +400,142 @@ func (d *driver) After() []string {
 }
 
 func (d *driver) Init() (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return true, d.openAllLocked()
+}
+
+// openAllLocked opens and registers all the connected devices.
+//
+// Devices are closed via CloseAll(), Rescan() or Dev.Close().
+func (d *driver) openAllLocked() error {
 	num, err := d.numDevices()
 	if err != nil {
-		return true, err
+		return err
 	}
-	multi := num > 1
 	for i := 0; i < num; i++ {
-		// TODO(maruel): Close the device one day. :)
-		if dev, err1 := open(d.d2xxOpen, i); err1 == nil {
+		h, err1 := openHandle(d.d2xxOpen, i)
+		if err1 = d.addLocked(i, h, err1, num == 1); err1 != nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+// addLocked initializes and registers the device at index i opened as h, and
+// adds it to the list of devices. openErr is the error returned by
+// openHandle(), if any.
+//
+// alias registers the short aliases of the pins; it must be true only when
+// a single device is connected.
+func (d *driver) addLocked(i int, h *handle, openErr error, alias bool) error {
+	err := openErr
+	if err == nil {
+		var dev Dev
+		if dev, err = open(h, i); err == nil {
 			d.all = append(d.all, dev)
-			if err = registerDev(dev, multi); err != nil {
-				return true, err
+			if alias {
+				d.aliased = dev
 			}
-		} else {
-			// Create a shallow broken handle, so the user can learn how to fix the
-			// problem.
-			//
-			// TODO(maruel): On macOS with a FT232R, calling two processes in a row
-			// often results in a broken device on the second process. Figure out why
-			// and make it more resilient.
-			err = err1
-			// The serial number is not available so what can be listed is limited.
-			// TODO(maruel): Add VID/PID?
-			name := "broken#" + strconv.Itoa(i) + ": " + err.Error()
-			d.all = append(d.all, &broken{index: i, err: err, name: name})
+			return registerDev(dev, !alias)
+		}
+	}
+	// Create a shallow broken handle, so the user can learn how to fix the
+	// problem.
+	//
+	// TODO(maruel): On macOS with a FT232R, calling two processes in a row
+	// often results in a broken device on the second process. Figure out why
+	// and make it more resilient.
+	//
+	// The USB descriptor is only known when the device could be opened.
+	// TODO(maruel): Use FT_GetDeviceInfoList() once periph.io/x/d2xx exposes
+	// it, to also identify the devices that failed to open.
+	name := "broken#" + strconv.Itoa(i) + ": " + err.Error()
+	b := &broken{index: i, err: err, name: name}
+	var o *openError
+	if errors.As(err, &o) {
+		b.info = Info{Type: o.t.String(), VenID: o.venID, DevID: o.devID}
+	}
+	d.all = append(d.all, b)
+	return err
+}
+
+// closeAllLocked closes all the devices.
+func (d *driver) closeAllLocked() error {
+	var errs []error
+	for len(d.all) != 0 {
+		errs = append(errs, d.closeLocked(d.all[len(d.all)-1]))
+	}
+	return errors.Join(errs...)
+}
+
+// closeLocked halts, unregisters and closes a device, and removes it from
+// the list of devices.
+func (d *driver) closeLocked(dev Dev) error {
+	if !d.removeLocked(dev) {
+		return nil
+	}
+	h, ok := dev.(interface{ getHandle() *handle })
+	if !ok {
+		// broken devices are neither registered nor opened.
+		return nil
+	}
+	multi := d.aliased != dev
+	if !multi {
+		d.aliased = nil
+	}
+	return errors.Join(dev.Halt(), unregisterDev(dev, multi), h.getHandle().Close())
+}
+
+// nameUsedLocked returns true if a device in the list of devices is named
+// name.
+func (d *driver) nameUsedLocked(name string) bool {
+	for _, dev := range d.all {
+		if dev.String() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// removeLocked removes a device from the list of devices. Returns false if
+// it was not found.
+func (d *driver) removeLocked(dev Dev) bool {
+	for i := range d.all {
+		if d.all[i] == dev {
+			d.all = append(d.all[:i], d.all[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// handleLocked returns the handle of the opened device at index i, or nil if
+// none.
+func (d *driver) handleLocked(i int) *handle {
+	for _, dev := range d.all {
+		if g, ok := dev.(interface {
+			getHandle() *handle
+			getIndex() int
+		}); ok && g.getIndex() == i {
+			return g.getHandle()
 		}
 	}
-	return true, err
+	return nil
 }
 
 func (d *driver) reset() {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.all = nil
+	_ = d.closeAllLocked()
 	// open is mocked in tests. You can also wrap d2xx.Open to return a wrapped
 	// d2xxtest.Log.
 	d.d2xxOpen = d2xx.Open
+	d.d2xxRescan = d2xx.Rescan
 	// numDevices is mocked in tests.
 	d.numDevices = numDevices
+	d.retries = 1
+	d.backoff = 0
 }
 
 func init() {
@@ -5,8 +5,15 @@
 package ftdi
 
 import (
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/conn/v3/spi"
+	"periph.io/x/conn/v3/spi/spireg"
 	"periph.io/x/d2xx"
 	"periph.io/x/d2xx/d2xxtest"
 )
@@ -33,6 +40,41 @@ func TestDriver(t *testing.T) {
 	}
 }
 
+func TestPinByName(t *testing.T) {
+	defer reset(t)
+	d := newFakeFT232R(t)
+	drv.all = []Dev{&broken{name: "broken#0"}, d}
+	if p := PinByName("FT232R.RX"); p != d.RX {
+		t.Fatalf("PinByName() = %v", p)
+	}
+	if p := PinByName("FT232R.D1"); p != nil {
+		t.Fatalf("PinByName() = %v", p)
+	}
+}
+
+// newFakeFT232R returns a FT232R backed by a d2xxtest.Fake.
+func newFakeFT232R(t *testing.T) *FT232R {
+	h := &handle{h: &d2xxtest.Fake{Data: [][]byte{{}, {0}}}, t: DevTypeFT232R}
+	f, err := newFT232R(generic{h: h, name: "FT232R"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// newFakeFT232H returns a FT232H backed by a fakeHandle. The bytes written
+// during initialization are discarded.
+func newFakeFT232H(t *testing.T) (*FT232H, *fakeHandle) {
+	fh := &fakeHandle{Fake: d2xxtest.Fake{Data: [][]byte{{0xFA, 0xAA}, {0xFA, 0xAB}}}}
+	h := &handle{h: fh, t: DevTypeFT232H}
+	f, err := newFT232H(generic{h: h, name: "FT232H"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh.W = nil
+	return f, fh
+}
+
 func reset(t *testing.T) {
 	drv.reset()
 }
@@ -40,3 +82,331 @@ func reset(t *testing.T) {
 func init() {
 	reset(nil)
 }
+
+func TestDriver_claimedByVCP(t *testing.T) {
+	defer reset(t)
+	drv.numDevices = func() (int, error) {
+		return 1, nil
+	}
+	drv.d2xxOpen = func(i int) (d2xx.Handle, d2xx.Err) {
+		return nil, ftDeviceNotOpened
+	}
+	if b, err := drv.Init(); !b || err == nil {
+		t.Fatalf("Init() = %t, %v", b, err)
+	}
+	if len(drv.all) != 1 {
+		t.Fatal(drv.all)
+	}
+	b, ok := drv.all[0].(*broken)
+	if !ok {
+		t.Fatalf("%T", drv.all[0])
+	}
+	if runtime.GOOS == "linux" && !strings.Contains(b.String(), "ftdi_sio") {
+		t.Fatal(b.String())
+	}
+	// The d2xx error is kept.
+	if !strings.Contains(b.String(), "Open: ") {
+		t.Fatal(b.String())
+	}
+}
+
+func TestDriver_brokenInfo(t *testing.T) {
+	defer reset(t)
+	drv.numDevices = func() (int, error) {
+		return 1, nil
+	}
+	drv.d2xxOpen = func(i int) (d2xx.Handle, d2xx.Err) {
+		d := &failInit{Fake: d2xxtest.Fake{DevType: uint32(DevTypeFT232H), Vid: 0x0403, Pid: 0x6014}}
+		return d, 0
+	}
+	if b, err := drv.Init(); !b || err == nil {
+		t.Fatalf("Init() = %t, %v", b, err)
+	}
+	var i Info
+	drv.all[0].Info(&i)
+	if want := (Info{Type: "FT232H", VenID: 0x0403, DevID: 0x6014}); i != want {
+		t.Fatalf("%+v", i)
+	}
+}
+
+// failInit is a d2xxtest.Fake that fails initialization.
+type failInit struct {
+	d2xxtest.Fake
+}
+
+func (f *failInit) SetUSBParameters(in, out int) d2xx.Err {
+	// FT_IO_ERROR
+	return 4
+}
+
+func TestDriver_openRetries(t *testing.T) {
+	defer reset(t)
+	drv.numDevices = func() (int, error) {
+		return 1, nil
+	}
+	drv.d2xxOpen = func(i int) (d2xx.Handle, d2xx.Err) {
+		d := &flakyInit{
+			// Each Reset() flushes the read buffer, consuming one empty read.
+			Fake:  d2xxtest.Fake{DevType: uint32(DevTypeFT232R), Data: [][]byte{{}, {}, {}, {0}}},
+			fails: 2,
+		}
+		return d, 0
+	}
+	// The default single retry is not enough.
+	if b, err := drv.Init(); !b || err == nil {
+		t.Fatalf("Init() = %t, %v", b, err)
+	}
+	if _, ok := drv.all[0].(*broken); !ok {
+		t.Fatalf("%T", drv.all[0])
+	}
+	SetOpenRetries(2, time.Millisecond)
+	drv.d2xxRescan = func() d2xx.Err {
+		return 0
+	}
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := drv.all[0].(*FT232R); !ok {
+		t.Fatalf("%T", drv.all[0])
+	}
+}
+
+// flakyInit is a d2xxtest.Fake that fails the first initializations.
+type flakyInit struct {
+	d2xxtest.Fake
+	fails int
+}
+
+func (f *flakyInit) SetUSBParameters(in, out int) d2xx.Err {
+	if f.fails > 0 {
+		f.fails--
+		// FT_IO_ERROR
+		return 4
+	}
+	return 0
+}
+
+func TestDriver_lifecycle(t *testing.T) {
+	defer reset(t)
+	drv.numDevices = func() (int, error) {
+		return 1, nil
+	}
+	drv.d2xxOpen = func(i int) (d2xx.Handle, d2xx.Err) {
+		d := &d2xxtest.Fake{
+			DevType: uint32(DevTypeFT232R),
+			Vid:     0x0403,
+			Pid:     0x6001,
+			Data:    [][]byte{{}, {0}},
+		}
+		return d, 0
+	}
+	rescans := 0
+	drv.d2xxRescan = func() d2xx.Err {
+		rescans++
+		return 0
+	}
+	if _, err := drv.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if gpioreg.ByName("FT232R.RX") == nil || gpioreg.ByName("RX") == nil {
+		t.Fatal("expected pins to be registered")
+	}
+	if err := CloseAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(All()) != 0 || gpioreg.ByName("FT232R.RX") != nil || gpioreg.ByName("RX") != nil {
+		t.Fatal("expected device to be unregistered")
+	}
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if rescans != 1 || len(All()) != 1 || gpioreg.ByName("FT232R.RX") == nil {
+		t.Fatal("expected device to be registered again")
+	}
+	if err := All()[0].Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(All()) != 0 || gpioreg.ByName("FT232R.RX") != nil {
+		t.Fatal("expected device to be unregistered")
+	}
+}
+
+func TestRescan_keepsDevices(t *testing.T) {
+	defer reset(t)
+	serials := []string{"FT1", "FT2"}
+	var opened []*unplugged
+	drv.numDevices = func() (int, error) {
+		return len(serials), nil
+	}
+	drv.d2xxOpen = func(i int) (d2xx.Handle, d2xx.Err) {
+		for _, o := range opened {
+			if !o.gone && o.E.Serial == serials[i] {
+				// FT_DEVICE_NOT_OPENED, as the device is already opened.
+				return nil, 3
+			}
+		}
+		d := &unplugged{Fake: d2xxtest.Fake{
+			DevType: uint32(DevTypeFT232R),
+			Data:    [][]byte{{}, {0}},
+			E:       d2xx.EEPROM{Serial: serials[i]},
+		}}
+		opened = append(opened, d)
+		return d, 0
+	}
+	drv.d2xxRescan = func() d2xx.Err {
+		return 0
+	}
+	if _, err := drv.Init(); err != nil {
+		t.Fatal(err)
+	}
+	before := All()
+	if len(before) != 2 {
+		t.Fatal(before)
+	}
+	// FT1 is unplugged and FT3 is plugged in.
+	opened[0].gone = true
+	serials = []string{"FT2", "FT3"}
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	after := All()
+	if len(after) != 2 || after[0] != before[1] {
+		t.Fatalf("FT2 was not kept: %v", after)
+	}
+	if len(opened) != 3 || after[1].(*FT232R).h.h != opened[2] {
+		t.Fatalf("FT3 was not opened: %v", after)
+	}
+	// The new device can't reuse the name of the device kept.
+	if after[0].String() != "FT232R(1)" || after[1].String() != "FT232R(2)" {
+		t.Fatalf("%s, %s", after[0], after[1])
+	}
+	if gpioreg.ByName("FT232R.RX") != nil || gpioreg.ByName("FT232R(2).RX") == nil {
+		t.Fatal("unexpected registration")
+	}
+}
+
+func TestRescan_brokenDevice(t *testing.T) {
+	defer reset(t)
+	serials := []string{"FT1"}
+	var opened []*unplugged
+	drv.numDevices = func() (int, error) {
+		return len(serials), nil
+	}
+	drv.d2xxOpen = func(i int) (d2xx.Handle, d2xx.Err) {
+		if serials[i] == "" {
+			// FT_DEVICE_NOT_FOUND, as the new device is not responding.
+			return nil, 2
+		}
+		for _, o := range opened {
+			if o.E.Serial == serials[i] {
+				return nil, ftDeviceNotOpened
+			}
+		}
+		d := &unplugged{Fake: d2xxtest.Fake{
+			DevType: uint32(DevTypeFT232R),
+			Data:    [][]byte{{}, {0}},
+			E:       d2xx.EEPROM{Serial: serials[i]},
+		}}
+		opened = append(opened, d)
+		return d, 0
+	}
+	drv.d2xxRescan = func() d2xx.Err {
+		return 0
+	}
+	if _, err := drv.Init(); err != nil {
+		t.Fatal(err)
+	}
+	before := All()
+	// A device that fails to open is plugged in.
+	serials = []string{"FT1", ""}
+	if err := Rescan(); err == nil {
+		t.Fatal("expected error")
+	}
+	after := All()
+	if len(after) != 2 || after[0] != before[0] {
+		t.Fatalf("FT1 was not kept: %v", after)
+	}
+	if b, ok := after[1].(*broken); !ok || b.index != 1 {
+		t.Fatalf("the new device was not recorded: %v", after)
+	}
+}
+
+// unplugged is a d2xxtest.Fake that can be disconnected.
+type unplugged struct {
+	d2xxtest.Fake
+	gone bool
+}
+
+func (u *unplugged) EEPROMRead(devType uint32, e *d2xx.EEPROM) d2xx.Err {
+	if u.gone {
+		// FT_IO_ERROR
+		return 4
+	}
+	return u.Fake.EEPROMRead(devType, e)
+}
+
+func TestListDescriptors(t *testing.T) {
+	defer reset(t)
+	drv.numDevices = func() (int, error) {
+		return 2, nil
+	}
+	drv.d2xxOpen = func(i int) (d2xx.Handle, d2xx.Err) {
+		if i == 1 {
+			// Claimed by another process.
+			return nil, 3
+		}
+		d := &d2xxtest.Fake{
+			DevType: uint32(DevTypeFT232H),
+			Vid:     0x0403,
+			Pid:     0x6014,
+			E:       d2xx.EEPROM{Desc: "Adapter", Serial: "FT123"},
+		}
+		return d, 0
+	}
+	got, err := ListDescriptors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Info{
+		{Opened: true, Type: "FT232H", VenID: 0x0403, DevID: 0x6014, Serial: "FT123", Desc: "Adapter"},
+		{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ListDescriptors() = %+v, want %+v", got, want)
+	}
+	if len(All()) != 0 {
+		t.Fatal("expected no device to be initialized")
+	}
+}
+
+func TestRegisterDev_spiPins(t *testing.T) {
+	ft232h, _ := newFakeFT232H(t)
+	ft232r := newFakeFT232R(t)
+	data := []struct {
+		d    Dev
+		pins [4]string
+	}{
+		{ft232h, [4]string{"FT232H.D0", "FT232H.D1", "FT232H.D2", "FT232H.D3"}},
+		{ft232r, [4]string{"FT232R.RTS", "FT232R.TX", "FT232R.RX", "FT232R.CTS"}},
+	}
+	for _, line := range data {
+		if err := registerDev(line.d, true); err != nil {
+			t.Fatal(err)
+		}
+		p, err := spireg.Open(line.d.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		pins, ok := p.(spi.Pins)
+		if !ok {
+			t.Fatalf("%T doesn't implement spi.Pins", p)
+		}
+		got := [4]string{pins.CLK().Name(), pins.MOSI().Name(), pins.MISO().Name(), pins.CS().Name()}
+		if got != line.pins {
+			t.Errorf("%s: got %v, want %v", line.d, got, line.pins)
+		}
+		if err := unregisterDev(line.d, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
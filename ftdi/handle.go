@@ -9,6 +9,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"runtime"
+	"sync"
+	"time"
 
 	"periph.io/x/conn/v3/physic"
 	"periph.io/x/d2xx"
@@ -42,6 +45,29 @@ const (
 	bitModeSyncFifo bitMode = 0x40
 )
 
+func (b bitMode) String() string {
+	switch b {
+	case bitModeReset:
+		return "UART"
+	case bitModeAsyncBitbang:
+		return "AsyncBitbang"
+	case bitModeMpsse:
+		return "MPSSE"
+	case bitModeSyncBitbang:
+		return "SyncBitbang"
+	case bitModeMcuHost:
+		return "MCUHost"
+	case bitModeFastSerial:
+		return "FastSerial"
+	case bitModeCbusBitbang:
+		return "CBusBitbang"
+	case bitModeSyncFifo:
+		return "SyncFIFO"
+	default:
+		return fmt.Sprintf("bitMode(%d)", uint8(b))
+	}
+}
+
 // numDevices returns the number of detected devices.
 func numDevices() (int, error) {
 	num, e := d2xx.CreateDeviceInfoList()
@@ -51,9 +77,17 @@ func numDevices() (int, error) {
 	return num, nil
 }
 
+// ftDeviceNotOpened is FT_DEVICE_NOT_OPENED.
+const ftDeviceNotOpened d2xx.Err = 3
+
 func openHandle(opener func(i int) (d2xx.Handle, d2xx.Err), i int) (*handle, error) {
 	h, e := opener(i)
 	if e != 0 {
+		if e == ftDeviceNotOpened && runtime.GOOS == "linux" {
+			// This is nearly always because the ftdi_sio kernel driver claimed the
+			// device. The generic "device busy" message confuses users.
+			return nil, fmt.Errorf("ftdi: device likely claimed by ftdi_sio; unbind it or add a udev rule; see https://periph.io/device/ftdi/ for help: %w", toErr("Open", e))
+		}
 		return nil, toErr("Open", e)
 	}
 	// For debugging:
@@ -81,15 +115,26 @@ type handle struct {
 	// Dev converts the int error type into Go native error and handles higher
 	// level functionality like reading and writing to the USB connection.
 	//
-	// The content of the struct is immutable after initialization.
+	// The content of the struct is immutable after initialization, except for
+	// the fields protected by mu.
 	h     d2xx.Handle
 	t     DevType
 	venID uint16
 	devID uint16
+
+	mu         sync.Mutex
+	halted     chan struct{}    // Closed by abort() to stop in-flight ReadAll() calls.
+	keepPins   bool             // Halt() leaves the GPIOs as-is when true.
+	readChunk  int              // Size of each read done by ReadAll(); 0 for defaultChunk.
+	writeChunk int              // Size of each write done by Write(); 0 for defaultChunk.
+	mode       bitMode          // Last mode set by SetBitMode().
+	clock      physic.Frequency // Last clock set by SetBaudRate() or MPSSEClock().
+
+	// owner is held by the user code between Dev.Acquire() and Dev.Release().
+	owner sync.Mutex
 }
 
 func (h *handle) Close() error {
-	// Not yet called.
 	return toErr("Close", h.h.Close())
 }
 
@@ -132,6 +177,50 @@ func (h *handle) Init() error {
 	return nil
 }
 
+// defaultChunk is the default size of each read and write on the USB
+// connection.
+const defaultChunk = 4096
+
+// SetUSBTransferSize sets the USB request transfer sizes, and the size of
+// each read and write done by ReadAll() and Write() to match.
+//
+// Smaller sizes reduce the latency of small transfers, larger sizes increase
+// the throughput of bulk transfers. The sizes must be a multiple of 64
+// between 64 and 65536; out can be 0 to keep the default. Beware that this
+// clears the device buffers.
+func (h *handle) SetUSBTransferSize(in, out int) error {
+	if in < 64 || in > 65536 || in%64 != 0 {
+		return fmt.Errorf("ftdi: invalid USB in transfer size %d", in)
+	}
+	if out != 0 && (out < 64 || out > 65536 || out%64 != 0) {
+		return fmt.Errorf("ftdi: invalid USB out transfer size %d", out)
+	}
+	if e := h.h.SetUSBParameters(in, out); e != 0 {
+		return toErr("SetUSBParameters", e)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readChunk = in
+	if out != 0 {
+		h.writeChunk = out
+	}
+	return nil
+}
+
+// chunks returns the size of each read and write.
+func (h *handle) chunks() (read, write int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	read, write = h.readChunk, h.writeChunk
+	if read == 0 {
+		read = defaultChunk
+	}
+	if write == 0 {
+		write = defaultChunk
+	}
+	return read, write
+}
+
 // InitNonMPSSE does initialization that should only be done when not in MPSSE
 // mode.
 func (h *handle) InitNonMPSSE() error {
@@ -173,7 +262,27 @@ func (h *handle) GetBitMode() (byte, error) {
 //
 // mask sets which pins are inputs and outputs for bitModeCbusBitbang.
 func (h *handle) SetBitMode(mask byte, mode bitMode) error {
-	return toErr("SetBitMode", h.h.SetBitMode(mask, byte(mode)))
+	if err := toErr("SetBitMode", h.h.SetBitMode(mask, byte(mode))); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.mode = mode
+	h.mu.Unlock()
+	return nil
+}
+
+// state returns the last mode and clock configured.
+func (h *handle) state() (bitMode, physic.Frequency) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.mode, h.clock
+}
+
+// setClock records the clock configured.
+func (h *handle) setClock(f physic.Frequency) {
+	h.mu.Lock()
+	h.clock = f
+	h.mu.Unlock()
 }
 
 // Flush flushes any data left in the read buffer.
@@ -190,6 +299,12 @@ func (h *handle) Flush() error {
 	}
 }
 
+// QueueStatus returns the number of bytes available in the read buffer.
+func (h *handle) QueueStatus() (int, error) {
+	p, e := h.h.GetQueueStatus()
+	return int(p), toErr("GetQueueStatus", e)
+}
+
 // Read returns as much as available in the read buffer without blocking.
 func (h *handle) Read(b []byte) (int, error) {
 	// GetQueueStatus() 60µs is relatively slow compared to Read() 4µs,
@@ -214,26 +329,107 @@ func (h *handle) Read(b []byte) (int, error) {
 // ReadAll blocks to return all the data.
 //
 // Similar to ioutil.ReadAll() except that it will stop if the context is
-// canceled.
+// canceled or if abort() is called.
+//
+// When no data is available, it polls with an increasing delay to not burn a
+// CPU core during long reads.
+//
+// TODO(maruel): Block on FT_SetEventNotification() with FT_EVENT_RXCHAR
+// instead once periph.io/x/d2xx exposes it.
 func (h *handle) ReadAll(ctx context.Context, b []byte) (int, error) {
-	// TODO(maruel): Use FT_SetEventNotification() instead of looping when
-	// waiting for bytes.
+	halted := h.haltChan()
+	maxChunk, _ := h.chunks()
+	var t *time.Timer
+	empty := 0
 	for offset := 0; offset != len(b); {
 		if ctx.Err() != nil {
 			return offset, io.EOF
 		}
-		chunk := len(b) - offset
-		if chunk > 4096 {
-			chunk = 4096
+		select {
+		case <-halted:
+			return offset, io.EOF
+		default:
 		}
+		chunk := min(len(b)-offset, maxChunk)
 		n, err := h.Read(b[offset : offset+chunk])
 		if offset += n; err != nil {
 			return offset, err
 		}
+		if n != 0 {
+			empty = 0
+			continue
+		}
+		// Spin first, as the data is usually received within a USB frame.
+		if empty++; empty <= pollSpins {
+			continue
+		}
+		d := time.Duration(empty-pollSpins) * pollStep
+		if d > pollMaxDelay {
+			d = pollMaxDelay
+		}
+		if t == nil {
+			t = time.NewTimer(d)
+			defer t.Stop()
+		} else {
+			t.Reset(d)
+		}
+		select {
+		case <-ctx.Done():
+		case <-halted:
+		case <-t.C:
+		}
 	}
 	return len(b), nil
 }
 
+const (
+	// pollSpins is the number of empty reads before ReadAll() starts to sleep.
+	pollSpins = 8
+	// pollStep is the increment of the sleep between empty reads.
+	pollStep = 10 * time.Microsecond
+	// pollMaxDelay is the maximum sleep between empty reads; it's the USB full
+	// speed frame duration.
+	pollMaxDelay = time.Millisecond
+)
+
+// abort stops all the in-flight ReadAll() calls.
+//
+// It is not sticky: ReadAll() calls started afterward are not affected, so a
+// transaction that didn't reach ReadAll() yet when abort() is called runs to
+// completion, and the next transaction runs normally.
+func (h *handle) abort() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.halted != nil {
+		close(h.halted)
+		h.halted = nil
+	}
+}
+
+// setKeepPins sets whether Halt() leaves the GPIOs in their current state.
+func (h *handle) setKeepPins(keep bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.keepPins = keep
+}
+
+// keepPinsOnHalt returns true if Halt() must leave the GPIOs as-is.
+func (h *handle) keepPinsOnHalt() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.keepPins
+}
+
+// haltChan returns the channel closed by the next abort() call.
+func (h *handle) haltChan() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.halted == nil {
+		h.halted = make(chan struct{})
+	}
+	return h.halted
+}
+
 // WriteFast writes to the USB device.
 //
 // In practice this takes at least 0.1ms, which limits the effective rate.
@@ -247,11 +443,9 @@ func (h *handle) WriteFast(b []byte) (int, error) {
 
 // Write blocks until all data is written.
 func (h *handle) Write(b []byte) (int, error) {
+	_, maxChunk := h.chunks()
 	for offset := 0; offset != len(b); {
-		chunk := len(b) - offset
-		if chunk > 4096 {
-			chunk = 4096
-		}
+		chunk := min(len(b)-offset, maxChunk)
 		p, err := h.WriteFast(b[offset : offset+chunk])
 		if err != nil {
 			return offset + p, err
@@ -330,6 +524,15 @@ func (h *handle) WriteEEPROM(ee *EEPROM) error {
 	return toErr("EEPROMWrite", h.h.EEPROMProgram(&ee2))
 }
 
+// WriteEEPROMWord writes a single 16 bits word at the word offset in the
+// EEPROM.
+func (h *handle) WriteEEPROMWord(offset uint16, value uint16) error {
+	if offset > 0xFF {
+		return fmt.Errorf("ftdi: EEPROM word offset %d is out of range", offset)
+	}
+	return toErr("WriteEE", h.h.WriteEE(uint8(offset), value))
+}
+
 // EraseEEPROM erases all the EEPROM.
 //
 // Will fail on FT232R and FT245R.
@@ -385,7 +588,11 @@ func (h *handle) SetBaudRate(f physic.Frequency) error {
 		return errors.New("ftdi: baud rate too high")
 	}
 	v := uint32(f / physic.Hertz)
-	return toErr("SetBaudRate", h.h.SetBaudRate(v))
+	if err := toErr("SetBaudRate", h.h.SetBaudRate(v)); err != nil {
+		return err
+	}
+	h.setClock(f)
+	return nil
 }
 
 //
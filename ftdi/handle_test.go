@@ -0,0 +1,118 @@
+// Copyright 2026 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package ftdi
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"periph.io/x/d2xx"
+	"periph.io/x/d2xx/d2xxtest"
+)
+
+func TestHandle_ReadAll_abort(t *testing.T) {
+	h := &handle{h: &d2xxtest.Fake{}}
+	done := make(chan error)
+	go func() {
+		var b [1]byte
+		_, err := h.ReadAll(context.Background(), b[:])
+		done <- err
+	}()
+	for {
+		h.abort()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			// ReadAll() works after an abort.
+			h.h = &d2xxtest.Fake{Data: [][]byte{{1}}}
+			var b [1]byte
+			if _, err := h.ReadAll(context.Background(), b[:]); err != nil || b[0] != 1 {
+				t.Fatal(err, b)
+			}
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestHandle_ReadAll_poll(t *testing.T) {
+	// More empty reads than pollSpins, so ReadAll() sleeps between polls.
+	data := make([][]byte, 2*pollSpins)
+	data = append(data, []byte{1})
+	h := &handle{h: &d2xxtest.Fake{Data: data}}
+	var b [1]byte
+	if _, err := h.ReadAll(context.Background(), b[:]); err != nil || b[0] != 1 {
+		t.Fatal(err, b)
+	}
+}
+
+// fakeHandle is a d2xxtest.Fake that records the bytes written.
+type fakeHandle struct {
+	d2xxtest.Fake
+	W []byte
+	N []int // Size of each Write() call.
+	// MaxW, when non-zero, caps the bytes accepted by each Write() call to
+	// simulate short writes.
+	MaxW int
+	// FailW, when non-zero, is returned by the next Write() call.
+	FailW d2xx.Err
+}
+
+// Write implements d2xx.Handle.
+func (f *fakeHandle) Write(b []byte) (int, d2xx.Err) {
+	if e := f.FailW; e != 0 {
+		f.FailW = 0
+		return 0, e
+	}
+	if f.MaxW != 0 && len(b) > f.MaxW {
+		b = b[:f.MaxW]
+	}
+	f.W = append(f.W, b...)
+	f.N = append(f.N, len(b))
+	return len(b), 0
+}
+
+// EEPROMRead implements d2xx.Handle.
+//
+// Unlike d2xxtest.Fake, it copies the raw data in the buffer provided, like
+// the real driver does.
+func (f *fakeHandle) EEPROMRead(devType uint32, e *d2xx.EEPROM) d2xx.Err {
+	copy(e.Raw, f.E.Raw)
+	e.Manufacturer = f.E.Manufacturer
+	e.ManufacturerID = f.E.ManufacturerID
+	e.Desc = f.E.Desc
+	e.Serial = f.E.Serial
+	return 0
+}
+
+func TestHandle_SetUSBTransferSize(t *testing.T) {
+	fh := &fakeHandle{}
+	h := &handle{h: fh}
+	for _, v := range [][2]int{{0, 0}, {100, 0}, {65600, 0}, {512, 65}} {
+		if h.SetUSBTransferSize(v[0], v[1]) == nil {
+			t.Fatalf("%v: expected error", v)
+		}
+	}
+	if err := h.SetUSBTransferSize(512, 1024); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Write(make([]byte, 3000)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fh.N, []int{1024, 1024, 952}) {
+		t.Fatal(fh.N)
+	}
+	// out is left as-is.
+	if err := h.SetUSBTransferSize(64, 0); err != nil {
+		t.Fatal(err)
+	}
+	if r, w := h.chunks(); r != 64 || w != 1024 {
+		t.Fatal(r, w)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 
 	"periph.io/x/conn/v3"
 	"periph.io/x/conn/v3/gpio"
@@ -29,17 +30,26 @@ const i2cSCL = 1    // D0
 const i2cSDAOut = 2 // D1
 const i2cSDAIn = 4  // D2
 
+// i2cTristate is the mask of the DBus pins used as open collector by the I²C
+// bus.
+const i2cTristate = i2cSCL | i2cSDAOut | i2cSDAIn
+
 type i2cBus struct {
-	f      *FT232H
-	pullUp bool
+	f            *FT232H
+	pullUp       bool
+	resetOnError bool
+	// actual is the I²C clock resulting from the MPSSE clock divisor.
+	actual physic.Frequency
 }
 
 // Close stops I²C mode, returns to high speed mode, disable tri-state.
 func (d *i2cBus) Close() error {
 	d.f.mu.Lock()
-	err := d.stopI2C()
-	d.f.mu.Unlock()
-	return err
+	defer d.f.mu.Unlock()
+	// Release SDA and SCL high first, so another master sharing the bus doesn't
+	// see a stuck line.
+	err := d.setI2CLinesIdle()
+	return errors.Join(err, d.stopI2C())
 }
 
 // Duplex implements conn.Conn.
@@ -61,28 +71,106 @@ func (d *i2cBus) SetSpeed(f physic.Frequency) error {
 	}
 	d.f.mu.Lock()
 	defer d.f.mu.Unlock()
-	_, err := d.f.h.MPSSEClock(f * 2 / 3)
-	return err
+	return d.setSpeedLocked(f)
+}
+
+// ActualSpeed returns the I²C clock actually used on the bus, computed back
+// from the MPSSE clock divisor. It can differ substantially from the speed
+// requested to SetSpeed() at high speeds, as the divisor is coarse.
+func (d *i2cBus) ActualSpeed() physic.Frequency {
+	d.f.mu.Lock()
+	defer d.f.mu.Unlock()
+	return d.actual
+}
+
+// setSpeedLocked sets the MPSSE clock for an I²C clock of f.
+//
+// With 3 phases clocking, each bit lasts 1.5 MPSSE clock period, so the
+// MPSSE clock must be 3/2 of the I²C clock.
+func (d *i2cBus) setSpeedLocked(f physic.Frequency) error {
+	clk, err := d.f.h.MPSSEClock(f * 3 / 2)
+	if err != nil {
+		return err
+	}
+	d.actual = clk * 2 / 3
+	// Report the bus speed, not the 3 phases clock.
+	d.f.h.setClock(d.actual)
+	return nil
 }
 
 // Tx implements i2c.Bus.
+//
+// When both w and r are specified, a repeated START is issued between the
+// write and the read phases, as expected by most devices to read a register.
 func (d *i2cBus) Tx(addr uint16, w, r []byte) error {
+	return d.TxContext(context.Background(), addr, w, r)
+}
+
+// TxContext is like Tx() but aborts the transaction once ctx is done, e.g.
+// when a device never acknowledges, and returns ctx.Err(). Halt() on the
+// device also aborts the transaction in flight.
+//
+// On abort, a STOP is issued and the bus is released without resetting the
+// USB device.
+func (d *i2cBus) TxContext(ctx context.Context, addr uint16, w, r []byte) (err error) {
 	d.f.mu.Lock()
 	defer d.f.mu.Unlock()
-	if err := d.setI2CStart(); err != nil {
+	defer func() {
+		if err != nil && d.resetOnError {
+			err = errors.Join(err, d.recoverLocked())
+		}
+	}()
+	err = d.txLocked(ctx, addr, w, r)
+	if !errors.Is(err, io.EOF) {
 		return err
 	}
-	a := [1]byte{byte(addr)}
-	if err := d.writeBytes(a[:]); err != nil {
+	// The wait for the reply was aborted. Discard the late reply, if any, and
+	// release the bus.
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return errors.Join(err, d.f.h.Flush(), d.setI2CStop(), d.setI2CLinesIdle())
+}
+
+// txLocked runs an I²C transaction.
+func (d *i2cBus) txLocked(ctx context.Context, addr uint16, w, r []byte) error {
+	if d.f.dbus.direction&(i2cSCL|i2cSDAOut) != i2cSCL|i2cSDAOut {
+		// Halt() set the pins as inputs; drive the bus again.
+		if err := d.setI2CLinesIdle(); err != nil {
+			return err
+		}
+	}
+	if err := d.setI2CStart(); err != nil {
 		return err
 	}
-	if len(w) != 0 {
-		if err := d.writeBytes(w); err != nil {
+	if len(w) != 0 || len(r) == 0 {
+		// Write phase.
+		a := [1]byte{byte(addr << 1)}
+		if err := d.writeBytes(ctx, a[:]); err != nil {
 			return err
 		}
+		if len(w) != 0 {
+			if err := d.writeBytes(ctx, w); err != nil {
+				return err
+			}
+		}
+		if len(r) != 0 {
+			// Repeated START.
+			if err := d.setI2CLinesIdle(); err != nil {
+				return err
+			}
+			if err := d.setI2CStart(); err != nil {
+				return err
+			}
+		}
 	}
 	if len(r) != 0 {
-		if err := d.readBytes(r); err != nil {
+		// Read phase.
+		a := [1]byte{byte(addr<<1) | 1}
+		if err := d.writeBytes(ctx, a[:]); err != nil {
+			return err
+		}
+		if err := d.readBytes(ctx, r); err != nil {
 			return err
 		}
 	}
@@ -92,6 +180,46 @@ func (d *i2cBus) Tx(addr uint16, w, r []byte) error {
 	return d.setI2CLinesIdle()
 }
 
+// SetResetOnError selects whether the MPSSE is reset after a failed
+// transaction, e.g. on a USB glitch, so the next one starts from a clean
+// command stream. The I²C configuration and speed are restored.
+//
+// It is disabled by default, as the reset adds latency to transient errors
+// like a NAK.
+func (d *i2cBus) SetResetOnError(enable bool) {
+	d.f.mu.Lock()
+	defer d.f.mu.Unlock()
+	d.resetOnError = enable
+}
+
+// recoverLocked resets the MPSSE after a failed transaction and restores the
+// I²C configuration and speed.
+func (d *i2cBus) recoverLocked() error {
+	_, f := d.f.h.state()
+	if err := d.f.resetMPSSELocked(); err != nil {
+		return err
+	}
+	if err := d.setupI2C(d.pullUp); err != nil {
+		return err
+	}
+	return d.setSpeedLocked(f)
+}
+
+// BusState reads the SCL (D0) and SDA (D2) lines while the bus is idle.
+//
+// Both lines are expected to be high. A line stuck low means missing pull up
+// resistors, a short or a device holding the bus. It helps diagnosing these
+// conditions before a transaction fails with an unexpected NAK.
+func (d *i2cBus) BusState() (sclHigh, sdaHigh bool, err error) {
+	d.f.mu.Lock()
+	defer d.f.mu.Unlock()
+	v, err := d.f.h.MPSSEDBusRead()
+	if err != nil {
+		return false, false, err
+	}
+	return v&i2cSCL != 0, v&i2cSDAIn != 0, nil
+}
+
 // SCL implements i2c.Pins.
 func (d *i2cBus) SCL() gpio.PinIO {
 	return d.f.D0
@@ -124,13 +252,20 @@ func (d *i2cBus) setupI2C(pullUp bool) error {
 		clock30MHz, byte(clk), byte(clk >> 8),
 	}
 	cmd := buf[:4]
+	tristate := d.f.dbus.tristate | i2cTristate
 	if !d.pullUp {
-		// TODO(maruel): Do not mess with other GPIOs tristate.
-		cmd = append(cmd, dataTristate, 7, 0)
+		// Only D0~D2 are tristated, the GPIOs D3~D7 are not touched.
+		cmd = append(cmd, dataTristate, tristate, d.f.cbus.tristate)
 	}
 	if _, err := d.f.h.Write(cmd); err != nil {
 		return err
 	}
+	if !d.pullUp {
+		d.f.dbus.tristate = tristate
+	}
+	// The MPSSE clock is 30MHz/(1+clk), 2/3 of which is the I²C clock.
+	d.actual = 30 * physic.MegaHertz / (1 + clk) * 2 / 3
+	d.f.h.setClock(d.actual)
 	d.f.usingI2C = true
 	d.pullUp = pullUp
 	return d.setI2CLinesIdle()
@@ -145,10 +280,13 @@ func (d *i2cBus) stopI2C() error {
 	}
 	cmd := buf[:4]
 	if !d.pullUp {
-		// TODO(maruel): Do not mess with other GPIOs tristate.
-		cmd = append(cmd, dataTristate, 0, 0)
+		d.f.dbus.tristate &^= i2cTristate
+		cmd = append(cmd, dataTristate, d.f.dbus.tristate, d.f.cbus.tristate)
 	}
 	_, err := d.f.h.Write(cmd)
+	if err == nil {
+		d.f.h.setClock(30 * physic.MegaHertz)
+	}
 	d.f.usingI2C = false
 	return err
 }
@@ -224,7 +362,7 @@ func (d *i2cBus) setI2CStop() error {
 // writeBytes writes multiple bytes within an I²C transaction.
 //
 // Does not touch D3~D7.
-func (d *i2cBus) writeBytes(w []byte) error {
+func (d *i2cBus) writeBytes(ctx context.Context, w []byte) error {
 	// TODO(maruel): d.pullUp
 	dir := d.f.dbus.direction
 	v := d.f.dbus.value
@@ -248,7 +386,7 @@ func (d *i2cBus) writeBytes(w []byte) error {
 		if _, err := d.f.h.Write(cmd[:]); err != nil {
 			return err
 		}
-		if _, err := d.f.h.ReadAll(context.Background(), r[:]); err != nil {
+		if _, err := d.f.h.ReadAll(ctx, r[:]); err != nil {
 			return err
 		}
 		if r[0]&1 == 0 {
@@ -261,7 +399,7 @@ func (d *i2cBus) writeBytes(w []byte) error {
 // readBytes reads multiple bytes within an I²C transaction.
 //
 // Does not touch D3~D7.
-func (d *i2cBus) readBytes(r []byte) error {
+func (d *i2cBus) readBytes(ctx context.Context, r []byte) error {
 	// TODO(maruel): d.pullUp
 	dir := d.f.dbus.direction
 	v := d.f.dbus.value
@@ -284,7 +422,7 @@ func (d *i2cBus) readBytes(r []byte) error {
 		if _, err := d.f.h.Write(cmd[:]); err != nil {
 			return err
 		}
-		if _, err := d.f.h.ReadAll(context.Background(), r[i:1]); err != nil {
+		if _, err := d.f.h.ReadAll(ctx, r[i:i+1]); err != nil {
 			return err
 		}
 	}
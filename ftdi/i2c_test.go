@@ -0,0 +1,235 @@
+// Copyright 2026 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package ftdi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/physic"
+)
+
+func TestI2CBus_Tx_repeatedStart(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	b, err := f.I2C(gpio.Float)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ACK for each of the 3 bytes written, then the 2 bytes read.
+	fh.Data = [][]byte{{}, {1}, {}, {1}, {}, {1}, {0xAA}, {0xBB}}
+	r := make([]byte, 2)
+	if err := b.Tx(0x50, []byte{0x10}, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, []byte{0xAA, 0xBB}) {
+		t.Fatalf("%#v", r)
+	}
+	// The address is sent twice: first for writing, then for reading after
+	// the repeated START.
+	out := func(c byte) int {
+		return bytes.Index(fh.W, []byte{dataOut | dataOutFall, 0, 0, c})
+	}
+	iw, ireg, ir := out(0xA0), out(0x10), out(0xA1)
+	if iw == -1 || ireg <= iw || ir <= ireg {
+		t.Fatal(iw, ireg, ir)
+	}
+}
+
+func TestI2CBus_BusState(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	b, err := f.I2C(gpio.Float)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := b.(interface {
+		BusState() (sclHigh, sdaHigh bool, err error)
+	})
+	if !ok {
+		t.Fatal("BusState not implemented")
+	}
+	// SDA stuck low.
+	fh.Data = [][]byte{{i2cSCL | i2cSDAOut}}
+	scl, sda, err := s.BusState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scl || sda {
+		t.Fatal(scl, sda)
+	}
+}
+
+func TestI2CBus_Close_idle(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	b, err := f.I2C(gpio.Float)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh.W = nil
+	fh.N = nil
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(fh.N) < 2 || fh.N[0] != 3 {
+		t.Fatalf("unexpected writes %v: %#v", fh.N, fh.W)
+	}
+	// The DBus command releases D0 (SCL) and D1 (SDA) high before the MPSSE
+	// is reset.
+	if c := fh.W[:3]; c[0] != gpioSetD || c[1]&(i2cSCL|i2cSDAOut) != i2cSCL|i2cSDAOut {
+		t.Fatalf("%#v", c)
+	}
+}
+
+func TestI2CBus_SetResetOnError(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	b, err := f.I2C(gpio.Float)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.(interface{ SetResetOnError(bool) }).SetResetOnError(true)
+	fh.W = nil
+	fh.FailW = 4
+	fh.Data = [][]byte{{}, {0xFA, 0xAA}, {0xFA, 0xAB}}
+	if b.Tx(0x10, []byte{1}, nil) == nil {
+		t.Fatal("expected error")
+	}
+	// The MPSSE is verified, then the I²C configuration is restored.
+	if !bytes.HasPrefix(fh.W, []byte{0xAA, flush, 0xAB, flush}) || bytes.IndexByte(fh.W, clock3Phase) == -1 {
+		t.Fatalf("MPSSE not reset: %#v", fh.W)
+	}
+	if _, clk := f.h.state(); clk != 400*physic.KiloHertz {
+		t.Fatal(clk)
+	}
+}
+
+func TestI2CBus_ActualSpeed(t *testing.T) {
+	f, _ := newFakeFT232H(t)
+	b, err := f.I2C(gpio.Float)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := b.(interface{ ActualSpeed() physic.Frequency })
+	if a := s.ActualSpeed(); a != 400*physic.KiloHertz {
+		t.Fatal(a)
+	}
+	if err := b.SetSpeed(100 * physic.KiloHertz); err != nil {
+		t.Fatal(err)
+	}
+	if a := s.ActualSpeed(); a != 100*physic.KiloHertz {
+		t.Fatal(a)
+	}
+	// The MPSSE clock is 30MHz/2, 3/2 of 10MHz.
+	if err := b.SetSpeed(10 * physic.MegaHertz); err != nil {
+		t.Fatal(err)
+	}
+	if a := s.ActualSpeed(); a != 10*physic.MegaHertz {
+		t.Fatal(a)
+	}
+	// 30MHz/5 is the closest MPSSE clock above 3/2 of 3.5MHz.
+	if err := b.SetSpeed(3500 * physic.KiloHertz); err != nil {
+		t.Fatal(err)
+	}
+	if a := s.ActualSpeed(); a != 4*physic.MegaHertz {
+		t.Fatal(a)
+	}
+}
+
+func TestI2CBus_TxContext(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	b, err := f.I2C(gpio.Float)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := b.(interface {
+		TxContext(ctx context.Context, addr uint16, w, r []byte) error
+	})
+	// The device never acknowledges the address.
+	fh.Data = nil
+	fh.W = nil
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tx.TxContext(ctx, 0x10, []byte{1}, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal(err)
+	}
+	// The bus is released with SCL and SDA high.
+	if c := fh.W[len(fh.W)-3:]; c[0] != gpioSetD || c[1]&(i2cSCL|i2cSDAOut) != i2cSCL|i2cSDAOut {
+		t.Fatalf("%#v", fh.W)
+	}
+	// Halt() aborts the transaction in flight. Keep the pins as-is so Halt()
+	// doesn't wait for the transaction to complete.
+	f.SetCloseBehavior(false)
+	done := make(chan error)
+	go func() {
+		done <- b.Tx(0x10, []byte{1}, nil)
+	}()
+	for {
+		select {
+		case err := <-done:
+			if !errors.Is(err, io.EOF) {
+				t.Fatal(err)
+			}
+			return
+		case <-time.After(time.Millisecond):
+			if err := f.Halt(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestI2CBus_TxAfterHalt(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	b, err := f.I2C(gpio.Float)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Halt() sets the pins as inputs by default.
+	if err := f.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if f.dbus.direction != 0 {
+		t.Fatalf("direction = %#x", f.dbus.direction)
+	}
+	fh.W = nil
+	// ACK for each of the 2 bytes written.
+	fh.Data = [][]byte{{}, {1}, {}, {1}}
+	if err := b.Tx(0x10, []byte{1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// SCL and SDA are driven before the START condition.
+	if len(fh.W) < 3 || fh.W[0] != gpioSetD || fh.W[2]&(i2cSCL|i2cSDAOut) != i2cSCL|i2cSDAOut {
+		t.Fatalf("%#v", fh.W)
+	}
+	if d := f.dbus.direction; d&(i2cSCL|i2cSDAOut) != i2cSCL|i2cSDAOut {
+		t.Fatalf("direction = %#x", d)
+	}
+}
+
+func TestI2CBus_tristate(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	b, err := f.I2C(gpio.Float)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.IndexByte(fh.W, dataTristate)
+	if i == -1 || i+2 >= len(fh.W) {
+		t.Fatalf("dataTristate not sent: %#v", fh.W)
+	}
+	// Only D0~D2 are open collector, D3~D7 are left alone.
+	if lo, hi := fh.W[i+1], fh.W[i+2]; lo != i2cTristate || hi != 0 {
+		t.Fatalf("tristate mask = %#x, %#x", lo, hi)
+	}
+	fh.W = nil
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(fh.W, []byte{dataTristate, 0, 0}) || f.dbus.tristate != 0 {
+		t.Fatalf("tristate not restored: %#v", fh.W)
+	}
+}
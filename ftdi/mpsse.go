@@ -141,7 +141,7 @@ const (
 	// <op>, <addrLow>, <data>
 	cpuWriteShort byte = 0x92
 	// <op>, <addrHi>, <addrLow>, <data>
-	cpuWriteFar byte = 0x91
+	cpuWriteFar byte = 0x93
 
 	// Buffer operations.
 	//
@@ -200,10 +200,30 @@ func (h *handle) InitMPSSE() error {
 	if _, err := h.Write(cmd); err != nil {
 		return err
 	}
+	// The happy path doesn't call SetBitMode().
+	h.mu.Lock()
+	h.mode = bitModeMpsse
+	h.mu.Unlock()
 	// Success!!
 	return nil
 }
 
+// ResetMPSSE resets only the MPSSE command processor, without resetting the
+// USB device, then verifies it is functional.
+func (h *handle) ResetMPSSE() error {
+	if err := h.SetBitMode(0, bitModeReset); err != nil {
+		return err
+	}
+	// Discard any stale reply from the desynchronized command stream.
+	if err := h.Flush(); err != nil {
+		return err
+	}
+	if err := h.SetBitMode(0, bitModeMpsse); err != nil {
+		return err
+	}
+	return h.mpsseVerify()
+}
+
 // mpsseVerify sends an invalid MPSSE command and verifies the returned value
 // is incorrect.
 //
@@ -256,23 +276,54 @@ func (h *handle) MPSSERegRead(addr uint16) (byte, error) {
 	return b[0], err
 }
 
+// MPSSERegWrite writes to the memory mapped registers of the device.
+func (h *handle) MPSSERegWrite(addr uint16, v byte) error {
+	// Unlike most other operations, the uint16 byte order is <hi>, <lo>.
+	b := [...]byte{cpuWriteFar, byte(addr >> 8), byte(addr), v}
+	_, err := h.Write(b[:])
+	return err
+}
+
 // MPSSEClock sets the clock at the closest value and returns it.
 func (h *handle) MPSSEClock(f physic.Frequency) (physic.Frequency, error) {
 	// TODO(maruel): Memory clock and skip if the same value.
-	clk := clock30MHz
+	clk, div, actual, err := mpsseDivisor(f)
+	if err != nil {
+		return 0, err
+	}
+	b := [...]byte{clk, clockSetDivisor, byte(div), byte(div >> 8)}
+	if _, err = h.Write(b[:]); err != nil {
+		return actual, err
+	}
+	h.setClock(actual)
+	return actual, nil
+}
+
+// mpsseDivisor calculates the base clock command and the divisor to program
+// to get the closest clock at or above f.
+//
+// clk is either clock30MHz or clock6MHz, div is the value to send along
+// clockSetDivisor and actual is the resulting clock frequency.
+func mpsseDivisor(f physic.Frequency) (clk byte, div uint16, actual physic.Frequency, err error) {
+	if f <= 0 {
+		return 0, 0, 0, fmt.Errorf("ftdi: invalid clock frequency %s", f)
+	}
+	clk = clock30MHz
 	base := 30 * physic.MegaHertz
-	div := base / f
-	if div >= 65536 {
+	d := base / f
+	if d >= 65536 {
 		clk = clock6MHz
 		base /= 5
-		div = base / f
-		if div >= 65536 {
-			return 0, errors.New("ftdi: clock frequency is too low")
+		d = base / f
+		if d >= 65536 {
+			return 0, 0, 0, errors.New("ftdi: clock frequency is too low")
 		}
 	}
-	b := [...]byte{clk, clockSetDivisor, byte(div - 1), byte((div - 1) >> 8)}
-	_, err := h.Write(b[:])
-	return base / div, err
+	if d < 1 {
+		// Faster than the base clock; use the maximum.
+		d = 1
+	}
+	return clk, uint16(d - 1), base / d, nil
 }
 
 // mpsseTxOp returns the right MPSSE command byte for the stream.
@@ -432,6 +483,21 @@ func (h *handle) MPSSEDBusRead() (byte, error) {
 	return b[0], nil
 }
 
+// MPSSEBusRead reads all the DBus pins D0~D7 and CBus pins C0~C7 in a single
+// USB round trip.
+func (h *handle) MPSSEBusRead() (dbus, cbus byte, err error) {
+	b := [...]byte{gpioReadD, gpioReadC, flush}
+	if _, err := h.Write(b[:]); err != nil {
+		return 0, 0, err
+	}
+	ctx, cancel := context200ms()
+	defer cancel()
+	if _, err := h.ReadAll(ctx, b[:2]); err != nil {
+		return 0, 0, err
+	}
+	return b[0], b[1], nil
+}
+
 func context200ms() (context.Context, func()) {
 	return context.WithTimeout(context.Background(), 200*time.Millisecond)
 }
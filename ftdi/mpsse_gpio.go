@@ -27,6 +27,9 @@ type gpiosMPSSE struct {
 	// Cache of values
 	direction byte
 	value     byte
+	// tristate is the mask of the outputs emulating open collector via
+	// dataTristate, as set by the I²C bus.
+	tristate byte
 }
 
 func (g *gpiosMPSSE) init(name string) {
@@ -91,6 +94,25 @@ func (g *gpiosMPSSE) out(n int, l gpio.Level) error {
 	return g.h.MPSSEDBus(g.direction, g.value)
 }
 
+// outOpenDrain emulates an open drain output: the pin drives low for Low and
+// is an input for High, so it is pulled up.
+func (g *gpiosMPSSE) outOpenDrain(n int, l gpio.Level) error {
+	if g.h == nil {
+		return errors.New("d2xx: device not open")
+	}
+	// The value is always low so the pin never drives high.
+	g.value &^= 1 << uint(n)
+	if l {
+		g.direction &^= 1 << uint(n)
+	} else {
+		g.direction |= 1 << uint(n)
+	}
+	if g.cbus {
+		return g.h.MPSSECBus(g.direction, g.value)
+	}
+	return g.h.MPSSEDBus(g.direction, g.value)
+}
+
 //
 
 // gpioMPSSE is a GPIO pin on a FTDI device driven via MPSSE.
@@ -181,6 +203,15 @@ func (g *gpioMPSSE) Out(l gpio.Level) error {
 	return g.a.out(g.num, l)
 }
 
+// OutOpenDrain emulates an open drain output, for bit banging a shared bus
+// like 1-wire.
+//
+// Low drives the pin low. High switches the pin to input, so the line floats
+// high via the pull up. Read() returns the level of the shared line.
+func (g *gpioMPSSE) OutOpenDrain(l gpio.Level) error {
+	return g.a.outOpenDrain(g.num, l)
+}
+
 // PWM implements gpio.PinOut.
 func (g *gpioMPSSE) PWM(d gpio.Duty, f physic.Frequency) error {
 	return errors.New("d2xx: not implemented")
@@ -0,0 +1,86 @@
+// Copyright 2026 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package ftdi
+
+import (
+	"bytes"
+	"testing"
+
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/d2xx/d2xxtest"
+)
+
+func TestHandle_MPSSERegWrite(t *testing.T) {
+	fh := &fakeHandle{}
+	h := &handle{h: fh, t: DevTypeFT232H}
+	if err := h.MPSSERegWrite(0x1234, 0x56); err != nil {
+		t.Fatal(err)
+	}
+	// The far write opcode is 0x93; 0x91 would be a far read.
+	if want := []byte{0x93, 0x12, 0x34, 0x56}; !bytes.Equal(fh.W, want) {
+		t.Fatalf("%#v != %#v", fh.W, want)
+	}
+}
+
+func TestCPUOpcodes(t *testing.T) {
+	ops := [...]byte{cpuReadShort, cpuReadFar, cpuWriteShort, cpuWriteFar}
+	for i := range ops {
+		for j := i + 1; j < len(ops); j++ {
+			if ops[i] == ops[j] {
+				t.Fatalf("opcode 0x%02X is duplicated", ops[i])
+			}
+		}
+	}
+}
+
+func TestHandle_MPSSEBusRead(t *testing.T) {
+	fh := &fakeHandle{Fake: d2xxtest.Fake{Data: [][]byte{{0x12, 0x34}}}}
+	h := &handle{h: fh, t: DevTypeFT232H}
+	d, c, err := h.MPSSEBusRead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 0x12 || c != 0x34 {
+		t.Fatalf("0x%02X 0x%02X", d, c)
+	}
+	// Both reads are queued in a single write.
+	if want := []byte{gpioReadD, gpioReadC, flush}; !bytes.Equal(fh.W, want) || len(fh.N) != 1 {
+		t.Fatalf("%#v %v", fh.W, fh.N)
+	}
+}
+
+func TestMPSSEDivisor(t *testing.T) {
+	data := []struct {
+		f      physic.Frequency
+		clk    byte
+		div    uint16
+		actual physic.Frequency
+	}{
+		{30 * physic.MegaHertz, clock30MHz, 0, 30 * physic.MegaHertz},
+		{100 * physic.MegaHertz, clock30MHz, 0, 30 * physic.MegaHertz},
+		{10 * physic.MegaHertz, clock30MHz, 2, 10 * physic.MegaHertz},
+		{400 * physic.KiloHertz, clock30MHz, 74, 400 * physic.KiloHertz},
+		// Last frequency on the 30MHz base clock.
+		{458 * physic.Hertz, clock30MHz, 65501, 30 * physic.MegaHertz / 65502},
+		// Switches to the 6MHz base clock.
+		{457 * physic.Hertz, clock6MHz, 13128, 6 * physic.MegaHertz / 13129},
+		// Lowest supported frequency.
+		{92 * physic.Hertz, clock6MHz, 65216, 6 * physic.MegaHertz / 65217},
+	}
+	for i, line := range data {
+		clk, div, actual, err := mpsseDivisor(line.f)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if clk != line.clk || div != line.div || actual != line.actual {
+			t.Fatalf("#%d: mpsseDivisor(%s) = 0x%02X, %d, %s", i, line.f, clk, div, actual)
+		}
+	}
+	for _, f := range []physic.Frequency{91 * physic.Hertz, 0, -1} {
+		if _, _, _, err := mpsseDivisor(f); err == nil {
+			t.Fatalf("mpsseDivisor(%s) should have failed", f)
+		}
+	}
+}
@@ -27,15 +27,12 @@ import (
 // command on the AD bus.
 type spiMPSEEPort struct {
 	c spiMPSEEConn
-
-	// Mutable.
-	maxFreq physic.Frequency
 }
 
 func (s *spiMPSEEPort) Close() error {
 	s.c.f.mu.Lock()
 	s.c.f.usingSPI = false
-	s.maxFreq = 0
+	s.c.maxFreq = 0
 	s.c.edgeInvert = false
 	s.c.clkActiveLow = false
 	s.c.noCS = false
@@ -49,15 +46,18 @@ func (s *spiMPSEEPort) String() string {
 	return s.c.f.String()
 }
 
+// ft232hMaxSPISpeed is the fastest SPI clock the MPSSE engine can generate.
+const ft232hMaxSPISpeed = 30 * physic.MegaHertz
+
 // Connect implements spi.Port.
 func (s *spiMPSEEPort) Connect(f physic.Frequency, m spi.Mode, bits int) (spi.Conn, error) {
 	if f > physic.GigaHertz {
 		return nil, fmt.Errorf("d2xx: invalid speed %s; maximum supported clock is 30MHz", f)
 	}
-	if f > 30*physic.MegaHertz {
+	if f > ft232hMaxSPISpeed {
 		// TODO(maruel): Figure out a way to communicate that the speed was lowered.
 		// https://github.com/google/periph/issues/255
-		f = 30 * physic.MegaHertz
+		f = ft232hMaxSPISpeed
 	}
 	if f < 100*physic.Hertz {
 		return nil, fmt.Errorf("d2xx: invalid speed %s; minimum supported clock is 100Hz; did you forget to multiply by physic.MegaHertz?", f)
@@ -83,13 +83,13 @@ func (s *spiMPSEEPort) Connect(f physic.Frequency, m spi.Mode, bits int) (spi.Co
 	}
 	s.c.edgeInvert = m&1 != 0
 	s.c.clkActiveLow = m&2 != 0
-	if s.maxFreq == 0 || f < s.maxFreq {
+	if s.c.maxFreq == 0 || f < s.c.maxFreq {
 		// TODO(maruel): We could set these only *during* the SPI operation, which
 		// would make more sense.
 		if _, err := s.c.f.h.MPSSEClock(f); err != nil {
 			return nil, err
 		}
-		s.maxFreq = f
+		s.c.maxFreq = f
 	}
 	s.c.resetIdle()
 	if err := s.c.f.h.MPSSEDBus(s.c.f.dbus.direction, s.c.f.dbus.value); err != nil {
@@ -112,13 +112,13 @@ func (s *spiMPSEEPort) LimitSpeed(f physic.Frequency) error {
 	}
 	s.c.f.mu.Lock()
 	defer s.c.f.mu.Unlock()
-	if s.maxFreq != 0 && s.maxFreq <= f {
+	if s.c.maxFreq != 0 && s.c.maxFreq <= f {
 		return nil
 	}
-	s.maxFreq = f
+	s.c.maxFreq = f
 	// TODO(maruel): We could set these only *during* the SPI operation, which
 	// would make more sense.
-	_, err := s.c.f.h.MPSSEClock(s.maxFreq)
+	_, err := s.c.f.h.MPSSEClock(s.c.maxFreq)
 	return err
 }
 
@@ -152,6 +152,10 @@ type spiMPSEEConn struct {
 	noCS         bool // CS line is not changed
 	lsbFirst     bool // Default is MSB first
 	halfDuplex   bool // 3 wire mode
+
+	// Mutable.
+	maxFreq      physic.Frequency
+	resetOnError bool
 }
 
 func (s *spiMPSEEConn) String() string {
@@ -163,12 +167,38 @@ func (s *spiMPSEEConn) Tx(w, r []byte) error {
 	return s.TxPackets(p[:])
 }
 
+// Mode returns the spi.Mode the connection was configured with by Connect().
+func (s *spiMPSEEConn) Mode() spi.Mode {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	return spiMode(s.edgeInvert, s.clkActiveLow, s.noCS, s.lsbFirst, s.halfDuplex)
+}
+
+// MaxSpeed returns the clock currently used on the bus, as set by Connect()
+// or lowered by LimitSpeed().
+func (s *spiMPSEEConn) MaxSpeed() physic.Frequency {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	return s.maxFreq
+}
+
+// SetResetOnError selects whether the MPSSE is reset after a failed
+// transaction, e.g. on a USB glitch, so the next one starts from a clean
+// command stream. The clock and the idle state of the pins are restored.
+//
+// It is disabled by default, as the reset adds latency to transient errors.
+func (s *spiMPSEEConn) SetResetOnError(enable bool) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	s.resetOnError = enable
+}
+
 func (s *spiMPSEEConn) Duplex() conn.Duplex {
 	// TODO(maruel): Support half if there's a need.
 	return conn.Full
 }
 
-func (s *spiMPSEEConn) TxPackets(pkts []spi.Packet) error {
+func (s *spiMPSEEConn) TxPackets(pkts []spi.Packet) (err error) {
 	// Verification.
 	for _, p := range pkts {
 		if p.KeepCS {
@@ -186,6 +216,11 @@ func (s *spiMPSEEConn) TxPackets(pkts []spi.Packet) error {
 	}
 	s.f.mu.Lock()
 	defer s.f.mu.Unlock()
+	defer func() {
+		if err != nil && s.resetOnError {
+			err = errors.Join(err, s.recoverLocked())
+		}
+	}()
 	const clk = byte(1) << 0
 	const mosi = byte(1) << 1
 	const miso = byte(1) << 2
@@ -268,7 +303,7 @@ func (s *spiMPSEEConn) TxPackets(pkts []spi.Packet) error {
 			cmd = append(cmd, op, byte(chunk-1), byte((chunk-1)>>8))
 			cmd = append(cmd, p.W[:chunk]...)
 			p.W = p.W[chunk:]
-			if _, err := s.f.h.WriteFast(cmd); err != nil {
+			if _, err := s.f.h.Write(cmd); err != nil {
 				return err
 			}
 			cmd = buf[:0]
@@ -292,7 +327,7 @@ func (s *spiMPSEEConn) TxPackets(pkts []spi.Packet) error {
 		if len(p.R) != 0 {
 			// Send a flush to not wait for data.
 			cmd = append(cmd, flush)
-			if _, err := s.f.h.WriteFast(cmd); err != nil {
+			if _, err := s.f.h.Write(cmd); err != nil {
 				return err
 			}
 			cmd = buf[:0]
@@ -311,7 +346,7 @@ func (s *spiMPSEEConn) TxPackets(pkts []spi.Packet) error {
 			for i := 0; i < 5; i++ {
 				cmd = append(cmd, gpioSetD, idle, s.f.dbus.direction)
 			}
-			if _, err := s.f.h.WriteFast(cmd); err != nil {
+			if _, err := s.f.h.Write(cmd); err != nil {
 				return err
 			}
 			cmd = buf[:0]
@@ -340,6 +375,16 @@ func (s *spiMPSEEConn) CS() gpio.PinOut {
 	return s.f.D3
 }
 
+// recoverLocked resets the MPSSE after a failed transaction and restores the
+// clock.
+func (s *spiMPSEEConn) recoverLocked() error {
+	if err := s.f.resetMPSSELocked(); err != nil {
+		return err
+	}
+	_, err := s.f.h.MPSSEClock(s.maxFreq)
+	return err
+}
+
 // resetIdle sets D0~D3. D0, D1 and D3 are output but only touch D3 is CS is
 // used.
 func (s *spiMPSEEConn) resetIdle() {
@@ -368,15 +413,12 @@ func (s *spiMPSEEConn) resetIdle() {
 // spiSyncPort is an SPI port over a FTDI device in synchronous bit-bang mode.
 type spiSyncPort struct {
 	c spiSyncConn
-
-	// Mutable.
-	maxFreq physic.Frequency
 }
 
 func (s *spiSyncPort) Close() error {
 	s.c.f.mu.Lock()
 	s.c.f.usingSPI = false
-	s.maxFreq = 0
+	s.c.maxFreq = 0
 	s.c.edgeInvert = false
 	s.c.clkActiveLow = false
 	s.c.noCS = false
@@ -418,19 +460,16 @@ func (s *spiSyncPort) Connect(f physic.Frequency, m spi.Mode, bits int) (spi.Con
 	s.c.halfDuplex = m&spi.HalfDuplex != 0
 	s.c.lsbFirst = m&spi.LSBFirst != 0
 	m &^= spi.NoCS | spi.HalfDuplex | spi.LSBFirst
-	if s.c.halfDuplex {
-		return nil, errors.New("d2xx: spi.HalfDuplex is not yet supported (implementing wouldn't be too hard, please submit a PR")
-	}
 	if m < 0 || m > 3 {
 		return nil, errors.New("d2xx: unknown spi mode")
 	}
 	s.c.edgeInvert = m&1 != 0
 	s.c.clkActiveLow = m&2 != 0
-	if s.maxFreq == 0 || f < s.maxFreq {
+	if s.c.maxFreq == 0 || f < s.c.maxFreq {
 		if err := s.c.f.SetSpeed(f * 2); err != nil {
 			return nil, err
 		}
-		s.maxFreq = f
+		s.c.maxFreq = f
 	}
 	// D0, D2 and D3 are output. D4~D7 are kept as-is.
 	const mosi = byte(1) << 0 // TX
@@ -469,11 +508,11 @@ func (s *spiSyncPort) LimitSpeed(f physic.Frequency) error {
 	}
 	s.c.f.mu.Lock()
 	defer s.c.f.mu.Unlock()
-	if s.maxFreq != 0 && s.maxFreq <= f {
+	if s.c.maxFreq != 0 && s.c.maxFreq <= f {
 		return nil
 	}
 	if err := s.c.f.SetSpeed(f * 2); err == nil {
-		s.maxFreq = f
+		s.c.maxFreq = f
 	}
 	return nil
 }
@@ -508,6 +547,9 @@ type spiSyncConn struct {
 	noCS         bool // CS line is not changed
 	lsbFirst     bool // Default is MSB first
 	halfDuplex   bool // 3 wire mode
+
+	// Mutable.
+	maxFreq physic.Frequency
 }
 
 func (s *spiSyncConn) String() string {
@@ -519,14 +561,35 @@ func (s *spiSyncConn) Tx(w, r []byte) error {
 	return s.TxPackets(p[:])
 }
 
+// Mode returns the spi.Mode the connection was configured with by Connect().
+func (s *spiSyncConn) Mode() spi.Mode {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	return spiMode(s.edgeInvert, s.clkActiveLow, s.noCS, s.lsbFirst, s.halfDuplex)
+}
+
+// MaxSpeed returns the clock currently used on the bus, as set by Connect()
+// or lowered by LimitSpeed().
+func (s *spiSyncConn) MaxSpeed() physic.Frequency {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	return s.maxFreq
+}
+
 func (s *spiSyncConn) Duplex() conn.Duplex {
-	// TODO(maruel): Support half if there's a need.
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	if s.halfDuplex {
+		return conn.Half
+	}
 	return conn.Full
 }
 
 func (s *spiSyncConn) TxPackets(pkts []spi.Packet) error {
 	// We need to 'expand' each bit 2 times * 8 bits, which leads
 	// to a 16x memory usage increase. Adds 5 samples before and after.
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
 	totalW := 0
 	totalR := 0
 	for _, p := range pkts {
@@ -539,7 +602,13 @@ func (s *spiSyncConn) TxPackets(pkts []spi.Packet) error {
 		if p.BitsPerWord != 0 && p.BitsPerWord != 8 {
 			return errors.New("d2xx: implement spi.Packet.BitsPerWord")
 		}
-		if err := verifyBuffers(p.W, p.R); err != nil {
+		// In half duplex, W is written then R is read, so they can differ in
+		// size.
+		verify := verifyBuffers
+		if s.halfDuplex {
+			verify = verifyBufferSizes
+		}
+		if err := verify(p.W, p.R); err != nil {
 			return err
 		}
 		// TODO(maruel): Correctly calculate offsets.
@@ -550,24 +619,15 @@ func (s *spiSyncConn) TxPackets(pkts []spi.Packet) error {
 			totalR += 2 * 8 * len(p.R)
 		}
 	}
-
-	// Create a large, single chunk.
-	var we, re []byte
-	if totalW != 0 {
-		totalW += 10
-		we = make([]byte, 0, totalW)
-	}
-	if totalR != 0 {
-		totalR += 10
-		re = make([]byte, totalR)
-	}
 	const mosi = byte(1) << 0 // TX
 	const miso = byte(1) << 1 // RX
 	const clk = byte(1) << 2  // RTS
 	const cs = byte(1) << 3   // CTS
 
-	s.f.mu.Lock()
-	defer s.f.mu.Unlock()
+	// Halt() sets all the pins as inputs; drive the bus again.
+	if err := s.f.setDBusMaskLocked(s.f.dmask | mosi | clk | cs); err != nil {
+		return err
+	}
 
 	// https://en.wikipedia.org/wiki/Serial_Peripheral_Interface#Data_transmission
 
@@ -582,36 +642,28 @@ func (s *spiSyncConn) TxPackets(pkts []spi.Packet) error {
 		clkActive, clkIdle = clkIdle, clkActive
 		csIdle |= clk
 	}
+	if s.halfDuplex {
+		return s.txHalfDuplexLocked(pkts, csIdle, clkIdle, clkActive)
+	}
+
+	// Create a large, single chunk.
+	var we, re []byte
+	if totalW != 0 {
+		totalW += 10
+		we = make([]byte, 0, totalW)
+	}
+	if totalR != 0 {
+		totalR += 10
+		re = make([]byte, totalR)
+	}
 	// Start of tx; assert CS if needed.
 	we = append(we, csIdle, clkIdle, clkIdle, clkIdle, clkIdle)
 	for _, p := range pkts {
 		if len(p.W) == 0 && len(p.R) == 0 {
 			continue
 		}
-		// TODO(maruel): s.halfDuplex.
 		for _, b := range p.W {
-			for j := uint(0); j < 8; j++ {
-				// For each bit, handle clock phase and data phase.
-				bit := byte(0)
-				if !s.lsbFirst {
-					// MSBF
-					if b&(0x80>>j) != 0 {
-						bit = mosi
-					}
-				} else {
-					// LSBF
-					if b&(1<<j) != 0 {
-						bit = mosi
-					}
-				}
-				if !s.edgeInvert {
-					// Mode0/2; CPHA=0
-					we = append(we, clkIdle|bit, clkActive|bit)
-				} else {
-					// Mode1/3; CPHA=1
-					we = append(we, clkActive|bit, clkIdle|bit)
-				}
-			}
+			we = s.appendByte(we, b, clkIdle, clkActive)
 		}
 	}
 	// End of tx; deassert CS.
@@ -627,27 +679,110 @@ func (s *spiSyncConn) TxPackets(pkts []spi.Packet) error {
 		if len(p.W) == 0 && len(p.R) == 0 {
 			continue
 		}
-		// TODO(maruel): halfDuplex.
 		for i := range p.R {
-			// For each bit, read at the right data phase.
-			b := byte(0)
-			for j := 0; j < 8; j++ {
-				if re[5+i*8*2+j*2+1]&byte(1)<<1 != 0 {
-					if !s.lsbFirst {
-						// MSBF
-						b |= 0x80 >> uint(j)
-					} else {
-						// LSBF
-						b |= 1 << uint(j)
-					}
-				}
-			}
-			p.R[i] = b
+			p.R[i] = s.decodeByte(re[5+i*8*2:], miso)
 		}
 	}
 	return nil
 }
 
+// txHalfDuplexLocked runs the transaction on a 3 wire bus, where D0 (TX) is
+// the shared data line. D0 is switched to an input while reading so the
+// device can drive it.
+//
+// Each packet writes W then reads R while CS is kept asserted.
+func (s *spiSyncConn) txHalfDuplexLocked(pkts []spi.Packet, csIdle, clkIdle, clkActive byte) error {
+	const data = byte(1) << 0 // TX
+	out := s.f.dmask | data
+	in := s.f.dmask &^ data
+	// Start of tx; assert CS if needed.
+	if err := s.f.txLocked([]byte{csIdle, clkIdle, clkIdle, clkIdle, clkIdle}, nil); err != nil {
+		return err
+	}
+	for _, p := range pkts {
+		if len(p.W) != 0 {
+			if err := s.f.setDBusMaskLocked(out); err != nil {
+				return err
+			}
+			we := make([]byte, 0, 2*8*len(p.W))
+			for _, b := range p.W {
+				we = s.appendByte(we, b, clkIdle, clkActive)
+			}
+			if err := s.f.txLocked(we, nil); err != nil {
+				return err
+			}
+		}
+		if len(p.R) != 0 {
+			if err := s.f.setDBusMaskLocked(in); err != nil {
+				return err
+			}
+			// Only toggle the clock; D0 is not driven.
+			we := make([]byte, 0, 2*8*len(p.R))
+			for range p.R {
+				we = s.appendByte(we, 0, clkIdle, clkActive)
+			}
+			re := make([]byte, len(we))
+			if err := s.f.txLocked(we, re); err != nil {
+				return err
+			}
+			for i := range p.R {
+				p.R[i] = s.decodeByte(re[i*8*2:], data)
+			}
+		}
+	}
+	// End of tx; deassert CS.
+	if err := s.f.setDBusMaskLocked(out); err != nil {
+		return err
+	}
+	return s.f.txLocked([]byte{clkIdle, clkIdle, clkIdle, clkIdle, csIdle}, nil)
+}
+
+// appendByte appends the 16 samples to clock out b on D0 (TX).
+func (s *spiSyncConn) appendByte(we []byte, b, clkIdle, clkActive byte) []byte {
+	const mosi = byte(1) << 0 // TX
+	for j := uint(0); j < 8; j++ {
+		// For each bit, handle clock phase and data phase.
+		bit := byte(0)
+		if !s.lsbFirst {
+			// MSBF
+			if b&(0x80>>j) != 0 {
+				bit = mosi
+			}
+		} else {
+			// LSBF
+			if b&(1<<j) != 0 {
+				bit = mosi
+			}
+		}
+		if !s.edgeInvert {
+			// Mode0/2; CPHA=0
+			we = append(we, clkIdle|bit, clkActive|bit)
+		} else {
+			// Mode1/3; CPHA=1
+			we = append(we, clkActive|bit, clkIdle|bit)
+		}
+	}
+	return we
+}
+
+// decodeByte decodes a byte from the 16 samples in re, reading the pin in
+// mask at each data phase.
+func (s *spiSyncConn) decodeByte(re []byte, mask byte) byte {
+	b := byte(0)
+	for j := 0; j < 8; j++ {
+		if re[j*2+1]&mask != 0 {
+			if !s.lsbFirst {
+				// MSBF
+				b |= 0x80 >> uint(j)
+			} else {
+				// LSBF
+				b |= 1 << uint(j)
+			}
+		}
+	}
+	return b
+}
+
 // CLK returns the SCK (clock) pin.
 func (s *spiSyncConn) CLK() gpio.PinOut {
 	return s.f.D2 // RTS
@@ -670,28 +805,50 @@ func (s *spiSyncConn) CS() gpio.PinOut {
 
 //
 
+// spiMode reconstructs the spi.Mode from the decoded connection flags.
+func spiMode(edgeInvert, clkActiveLow, noCS, lsbFirst, halfDuplex bool) spi.Mode {
+	m := spi.Mode0
+	if edgeInvert {
+		m |= spi.Mode1
+	}
+	if clkActiveLow {
+		m |= spi.Mode2
+	}
+	if noCS {
+		m |= spi.NoCS
+	}
+	if lsbFirst {
+		m |= spi.LSBFirst
+	}
+	if halfDuplex {
+		m |= spi.HalfDuplex
+	}
+	return m
+}
+
 func verifyBuffers(w, r []byte) error {
-	if len(w) != 0 {
-		if len(r) != 0 {
-			if len(w) != len(r) {
-				return errors.New("d2xx: both buffers must have the same size")
-			}
-		}
-		// TODO(maruel): When the buffer is >64Kb, cut it in parts and do not
-		// request a flush. Still try to read though.
-		if len(w) > 65536 {
-			return errors.New("d2xx: maximum buffer size is 64Kb")
-		}
-	} else if len(r) != 0 {
-		// TODO(maruel): Remove, this is not a problem.
-		if len(r) > 65536 {
-			return errors.New("d2xx: maximum buffer size is 64Kb")
-		}
+	if len(w) != 0 && len(r) != 0 && len(w) != len(r) {
+		return errors.New("d2xx: both buffers must have the same size")
+	}
+	return verifyBufferSizes(w, r)
+}
+
+// verifyBufferSizes only checks the size limits, for transfers where w and r
+// are not clocked at the same time.
+func verifyBufferSizes(w, r []byte) error {
+	// TODO(maruel): When the buffer is >64Kb, cut it in parts and do not
+	// request a flush. Still try to read though.
+	if len(w) > 65536 || len(r) > 65536 {
+		return errors.New("d2xx: maximum buffer size is 64Kb")
 	}
 	return nil
 }
 
 var _ spi.PortCloser = &spiMPSEEPort{}
+var _ spi.Pins = &spiMPSEEPort{}
 var _ spi.Conn = &spiMPSEEConn{}
+var _ spi.Pins = &spiMPSEEConn{}
 var _ spi.PortCloser = &spiSyncPort{}
+var _ spi.Pins = &spiSyncPort{}
 var _ spi.Conn = &spiSyncConn{}
+var _ spi.Pins = &spiSyncConn{}
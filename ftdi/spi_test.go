@@ -0,0 +1,235 @@
+// Copyright 2026 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package ftdi
+
+import (
+	"bytes"
+	"testing"
+
+	"periph.io/x/conn/v3"
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/conn/v3/spi"
+	"periph.io/x/d2xx/d2xxtest"
+)
+
+func TestSPIMPSEEConn_shortWrite(t *testing.T) {
+	tx := func(maxW int) *fakeHandle {
+		f, fh := newFakeFT232H(t)
+		p, err := f.SPI()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := p.Connect(physic.MegaHertz, spi.Mode0, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fh.W = nil
+		fh.N = nil
+		fh.MaxW = maxW
+		w := make([]byte, 1000)
+		for i := range w {
+			w[i] = byte(i)
+		}
+		if err := c.Tx(w, nil); err != nil {
+			t.Fatal(err)
+		}
+		return fh
+	}
+	want := tx(0)
+	got := tx(7)
+	if !bytes.Equal(got.W, want.W) {
+		t.Fatalf("short writes truncated the command:\n%x\n%x", got.W, want.W)
+	}
+	for _, n := range got.N {
+		if n > 7 {
+			t.Fatalf("unexpected write of %d bytes", n)
+		}
+	}
+}
+
+func TestSPIConn_Mode(t *testing.T) {
+	f, _ := newFakeFT232H(t)
+	p, err := f.SPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	c, err := p.Connect(physic.GigaHertz/2, spi.Mode3|spi.LSBFirst, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := c.(*spiMPSEEConn)
+	if m := mc.Mode(); m != spi.Mode3|spi.LSBFirst {
+		t.Fatalf("Mode() = %s", m)
+	}
+	if s := mc.MaxSpeed(); s != ft232hMaxSPISpeed {
+		t.Fatalf("MaxSpeed() = %s", s)
+	}
+	if err := p.LimitSpeed(physic.MegaHertz); err != nil {
+		t.Fatal(err)
+	}
+	if s := mc.MaxSpeed(); s != physic.MegaHertz {
+		t.Fatalf("MaxSpeed() = %s", s)
+	}
+
+	r := newFakeFT232R(t)
+	rp, err := r.SPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+	rc, err := rp.Connect(physic.MegaHertz, spi.Mode1|spi.NoCS, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := rc.(*spiSyncConn)
+	if m := sc.Mode(); m != spi.Mode1|spi.NoCS {
+		t.Fatalf("Mode() = %s", m)
+	}
+	if s := sc.MaxSpeed(); s != physic.MegaHertz {
+		t.Fatalf("MaxSpeed() = %s", s)
+	}
+}
+
+func TestSPIConn_SetResetOnError(t *testing.T) {
+	f, fh := newFakeFT232H(t)
+	p, err := f.SPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Connect(physic.MegaHertz, spi.Mode0, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Disabled by default.
+	fh.W = nil
+	fh.FailW = 4
+	if c.Tx([]byte{1}, nil) == nil {
+		t.Fatal("expected error")
+	}
+	if len(fh.W) != 0 {
+		t.Fatalf("unexpected writes %#v", fh.W)
+	}
+	c.(interface{ SetResetOnError(bool) }).SetResetOnError(true)
+	fh.FailW = 4
+	fh.Data = [][]byte{{}, {0xFA, 0xAA}, {0xFA, 0xAB}}
+	if c.Tx([]byte{1}, nil) == nil {
+		t.Fatal("expected error")
+	}
+	// The MPSSE is verified, then the GPIOs and the clock are restored.
+	if !bytes.HasPrefix(fh.W, []byte{0xAA, flush, 0xAB, flush, gpioSetD}) {
+		t.Fatalf("MPSSE not reset: %#v", fh.W)
+	}
+	if fh.W[len(fh.W)-4] != clock30MHz {
+		t.Fatalf("clock not restored: %#v", fh.W)
+	}
+}
+
+func TestSPISyncConn_halfDuplex(t *testing.T) {
+	fh := &fakeHandle{Fake: d2xxtest.Fake{Data: [][]byte{{}, {0}}}}
+	f, err := newFT232R(generic{h: &handle{h: fh, t: DevTypeFT232R}, name: "FT232R"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := f.SPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Connect(physic.MegaHertz, spi.Mode0|spi.HalfDuplex, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := c.Duplex(); d != conn.Half {
+		t.Fatal(d)
+	}
+	// The device drives 0xA5 on D0 at each data phase.
+	var samples []byte
+	for j := 0; j < 8; j++ {
+		samples = append(samples, 0, (0xA5>>(7-j))&1)
+	}
+	fh.Data = [][]byte{samples}
+	fh.W = nil
+	r := make([]byte, 1)
+	if err := c.Tx([]byte{0x80}, r); err != nil {
+		t.Fatal(err)
+	}
+	if r[0] != 0xA5 {
+		t.Fatalf("read %#x", r[0])
+	}
+	// CS asserted, 0x80 clocked out MSB first, the read clock cycles with D0
+	// released, then CS deasserted.
+	const clk = 1 << 2
+	want := []byte{1 << 3, 0, 0, 0, 0, 1, 1 | clk}
+	for j := 1; j < 16; j++ {
+		want = append(want, 0, clk)
+	}
+	want = append(want, 0, 0, 0, 0, 1<<3)
+	if !bytes.Equal(fh.W, want) {
+		t.Fatalf("%#v", fh.W)
+	}
+}
+
+func TestSPISyncConn_halfDuplexSizes(t *testing.T) {
+	fh := &fakeHandle{Fake: d2xxtest.Fake{Data: [][]byte{{}, {0}}}}
+	f, err := newFT232R(generic{h: &handle{h: fh, t: DevTypeFT232R}, name: "FT232R"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := f.SPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Connect(physic.MegaHertz, spi.Mode0|spi.HalfDuplex, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A one byte command followed by a three bytes response.
+	in := []byte{0x12, 0x34, 0x56}
+	var samples []byte
+	for _, b := range in {
+		for j := 0; j < 8; j++ {
+			samples = append(samples, 0, (b>>(7-j))&1)
+		}
+	}
+	fh.Data = [][]byte{samples}
+	r := make([]byte, 3)
+	if err := c.Tx([]byte{0x9F}, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, in) {
+		t.Fatalf("read %#v", r)
+	}
+}
+
+func TestSPISyncConn_TxAfterHalt(t *testing.T) {
+	fh := &fakeHandle{Fake: d2xxtest.Fake{Data: [][]byte{{}, {0}}}}
+	f, err := newFT232R(generic{h: &handle{h: fh, t: DevTypeFT232R}, name: "FT232R"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := f.SPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Connect(physic.MegaHertz, spi.Mode0, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if f.dmask != 0 {
+		t.Fatalf("%#x", f.dmask)
+	}
+	// 5 samples before and after the 16 samples of the byte.
+	fh.Data = [][]byte{make([]byte, 5+16+5)}
+	if err := c.Tx([]byte{0x80}, make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+	// MOSI, CLK and CS are outputs again.
+	if f.dmask != 0x0D {
+		t.Fatalf("%#x", f.dmask)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package ftdi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"periph.io/x/conn/v3"
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/conn/v3/uart"
+)
+
+// uartPort implements uart.PortCloser over the UART of a FT-X device.
+//
+// The d2xx wrapper doesn't expose FT_SetDataCharacteristics(), so only the
+// power up default of 8 bits, no parity and one stop bit is supported.
+type uartPort struct {
+	f       *FTX
+	maxFreq physic.Frequency
+}
+
+// Close implements uart.PortCloser.
+func (u *uartPort) Close() error {
+	u.f.mu.Lock()
+	defer u.f.mu.Unlock()
+	u.f.usingUART = false
+	u.maxFreq = 0
+	return nil
+}
+
+func (u *uartPort) String() string {
+	return u.f.String()
+}
+
+// LimitSpeed implements uart.PortCloser.
+func (u *uartPort) LimitSpeed(f physic.Frequency) error {
+	if f <= 0 || f > 3*physic.MegaHertz {
+		return fmt.Errorf("d2xx: invalid speed %s; maximum supported baud rate is 3MHz", f)
+	}
+	u.f.mu.Lock()
+	defer u.f.mu.Unlock()
+	u.maxFreq = f
+	return nil
+}
+
+// Connect implements uart.Port.
+func (u *uartPort) Connect(f physic.Frequency, stopBit uart.Stop, parity uart.Parity, flow uart.Flow, bits int) (conn.Conn, error) {
+	if f <= 0 || f > 3*physic.MegaHertz {
+		return nil, fmt.Errorf("d2xx: invalid speed %s; maximum supported baud rate is 3MHz", f)
+	}
+	if stopBit != uart.One || parity != uart.NoParity || bits != 8 {
+		return nil, errors.New("d2xx: only 8 bits, no parity and one stop bit is supported")
+	}
+	if flow != uart.NoFlow {
+		return nil, errors.New("d2xx: flow control is not supported")
+	}
+	u.f.mu.Lock()
+	defer u.f.mu.Unlock()
+	if u.maxFreq != 0 && u.maxFreq < f {
+		f = u.maxFreq
+	}
+	if err := u.f.h.SetBaudRate(f); err != nil {
+		return nil, err
+	}
+	return &uartConn{f: u.f}, nil
+}
+
+// uartConn implements conn.Conn over the UART of a FT-X device.
+type uartConn struct {
+	f *FTX
+}
+
+func (c *uartConn) String() string {
+	return c.f.String()
+}
+
+// Tx implements conn.Conn.
+//
+// It writes w, then blocks until len(r) bytes are received. Halt() on the
+// device interrupts a read in flight, in which case io.EOF is returned.
+func (c *uartConn) Tx(w, r []byte) error {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	if len(w) != 0 {
+		if _, err := c.f.h.Write(w); err != nil {
+			return err
+		}
+	}
+	if len(r) != 0 {
+		if _, err := c.f.h.ReadAll(context.Background(), r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Duplex implements conn.Conn.
+func (c *uartConn) Duplex() conn.Duplex {
+	return conn.Full
+}
+
+// RX implements uart.Pins.
+//
+// The RX pin is not exposed as a GPIO.
+func (c *uartConn) RX() gpio.PinIn {
+	return gpio.INVALID
+}
+
+// TX implements uart.Pins.
+//
+// The TX pin is not exposed as a GPIO.
+func (c *uartConn) TX() gpio.PinOut {
+	return gpio.INVALID
+}
+
+// RTS implements uart.Pins.
+func (c *uartConn) RTS() gpio.PinOut {
+	return gpio.INVALID
+}
+
+// CTS implements uart.Pins.
+func (c *uartConn) CTS() gpio.PinIn {
+	return gpio.INVALID
+}
+
+var _ uart.PortCloser = &uartPort{}
+var _ conn.Conn = &uartConn{}
+var _ uart.Pins = &uartConn{}
@@ -9,9 +9,17 @@
 package gpioioctl
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"periph.io/x/conn/v3/gpio"
 	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/conn/v3/physic"
 )
 
 var testLine *GPIOLine
@@ -107,3 +115,225 @@ func TestString(t *testing.T) {
 		t.Errorf("GPIOLine.String() failed.")
 	}
 }
+
+func TestSortChips(t *testing.T) {
+	defer SetPreferredChip("")
+	chips := []*GPIOChip{
+		{name: "gpiochip10", label: "pinctrl-rp1"},
+		{name: "gpiochip2", label: "other"},
+		{name: "gpiochip4", label: "pinctrl-rp1"},
+		{name: "gpiochip0", label: "pinctrl-bcm2712"},
+	}
+	sortChips(chips)
+	want := []string{"gpiochip0", "gpiochip4", "gpiochip10", "gpiochip2"}
+	for i, c := range chips {
+		if c.Name() != want[i] {
+			t.Fatalf("chips[%d] = %s; want %s", i, c.Name(), want[i])
+		}
+	}
+	SetPreferredChip("other")
+	sortChips(chips)
+	if chips[0].Name() != "gpiochip2" {
+		t.Fatalf("preferred chip not first: %s", chips[0].Name())
+	}
+}
+
+func TestButtonUnknownLine(t *testing.T) {
+	if _, _, err := Chips[0].Button("DoesNotExist", time.Millisecond); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestChipByName(t *testing.T) {
+	first := &GPIOLine{number: 0, name: "DUP"}
+	chip := &GPIOChip{lines: []*GPIOLine{first, {number: 1, name: "DUP"}, {number: 2, name: "OTHER"}}}
+	if l := chip.ByName("DUP"); l != first {
+		t.Fatalf("ByName(DUP) = %v", l)
+	}
+	if l := chip.ByName("OTHER"); l == nil || l.Number() != 2 {
+		t.Fatalf("ByName(OTHER) = %v", l)
+	}
+	if l := chip.ByName("MISSING"); l != nil {
+		t.Fatalf("ByName(MISSING) = %v", l)
+	}
+}
+
+func TestLineNotFoundError(t *testing.T) {
+	other := &GPIOChip{name: "gpiochip9", label: "other", lines: []*GPIOLine{{name: "OtherChipLine"}}}
+	defer func(old []*GPIOChip) { Chips = old }(Chips)
+	Chips = append(Chips[:len(Chips):len(Chips)], other)
+	_, err := Chips[0].LineSet(LineInput, gpio.NoEdge, gpio.PullNoChange, "OtherChipLine")
+	var lnf *LineNotFoundError
+	if !errors.As(err, &lnf) || lnf.OtherChip != "other" {
+		t.Fatal(err)
+	}
+}
+
+func TestPulseInvalid(t *testing.T) {
+	line := &GPIOLine{name: "PulseLine"}
+	if err := line.Pulse(-1, time.Millisecond, time.Millisecond); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestValidateFlags(t *testing.T) {
+	data := []struct {
+		dir  LineDir
+		edge gpio.Edge
+		pull gpio.Pull
+		ok   bool
+	}{
+		{LineInput, gpio.BothEdges, gpio.PullUp, true},
+		{LineOutput, gpio.NoEdge, gpio.PullDown, true},
+		{LineOutput, gpio.RisingEdge, gpio.PullNoChange, false},
+		{LineDirNotSet, gpio.FallingEdge, gpio.PullNoChange, false},
+		{LineDirNotSet, gpio.NoEdge, gpio.PullUp, false},
+		{LineInput, gpio.Edge(4), gpio.PullNoChange, false},
+		{LineDir(3), gpio.NoEdge, gpio.PullNoChange, false},
+	}
+	for i, line := range data {
+		if err := validateFlags(line.dir, line.edge, line.pull); (err == nil) != line.ok {
+			t.Errorf("#%d: %v", i, err)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	s := Dump()
+	if !strings.Contains(s, Chips[0].Name()) {
+		t.Fatal(s)
+	}
+}
+
+func TestGetFlagsBias(t *testing.T) {
+	const bias = _GPIO_V2_LINE_FLAG_BIAS_PULL_UP | _GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN | _GPIO_V2_LINE_FLAG_BIAS_DISABLED
+	data := []struct {
+		pull gpio.Pull
+		want uint64
+	}{
+		{gpio.PullNoChange, 0},
+		{gpio.Float, _GPIO_V2_LINE_FLAG_BIAS_DISABLED},
+		{gpio.PullDown, _GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN},
+		{gpio.PullUp, _GPIO_V2_LINE_FLAG_BIAS_PULL_UP},
+	}
+	for _, line := range data {
+		flags := getFlags(LineInput, gpio.NoEdge, line.pull)
+		if flags&bias != line.want {
+			t.Errorf("%s: got 0x%x; want 0x%x", line.pull, flags&bias, line.want)
+		}
+		if flags&_GPIO_V2_LINE_FLAG_INPUT == 0 {
+			t.Errorf("%s: missing input flag", line.pull)
+		}
+	}
+}
+
+func TestIoctlError(t *testing.T) {
+	var err error = &IoctlError{Op: "GPIO_V2_GET_LINE_IOCTL", Errno: syscall.EBUSY}
+	if s := err.Error(); !strings.HasPrefix(s, "GPIO_V2_GET_LINE_IOCTL: ") {
+		t.Error(s)
+	}
+	err = requestError("LineSetFromConfig", err)
+	if !errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EINVAL) {
+		t.Errorf("unexpected classification: %v", err)
+	}
+	if !strings.Contains(err.Error(), "already in use") {
+		t.Error(err)
+	}
+	var ie *IoctlError
+	if !errors.As(err, &ie) || ie.Errno != syscall.EBUSY {
+		t.Errorf("IoctlError not preserved: %v", err)
+	}
+	err = requestError("LineSetFromConfig", &IoctlError{Op: "GPIO_V2_GET_LINE_IOCTL", Errno: syscall.EACCES})
+	if !errors.Is(err, os.ErrPermission) {
+		t.Errorf("expected permission error: %v", err)
+	}
+}
+
+func TestIsNoUAPIv2(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.EINVAL, syscall.ENOTTY} {
+		if !isNoUAPIv2(&IoctlError{Op: "GPIO_V2_GET_LINEINFO_IOCTL", Errno: errno}) {
+			t.Errorf("%s: expected v2 to be unsupported", errno)
+		}
+	}
+	if isNoUAPIv2(&IoctlError{Op: "GPIO_V2_GET_LINEINFO_IOCTL", Errno: syscall.EBUSY}) {
+		t.Error("EBUSY doesn't mean v2 is unsupported")
+	}
+}
+
+func TestReadAllNoLines(t *testing.T) {
+	chip := &GPIOChip{}
+	m, err := chip.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 0 {
+		t.Errorf("ReadAll() = %v", m)
+	}
+}
+
+func TestDeviceID(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "gpiochip0")
+	b := filepath.Join(dir, "gpiochip1")
+	for _, p := range []string{a, b} {
+		if err := os.WriteFile(p, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(dir, "gpiochip4")
+	if err := os.Symlink(a, link); err != nil {
+		t.Skip(err)
+	}
+	open := func(p string) *GPIOChip {
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = f.Close() })
+		// Same name for all, like distinct controllers sharing a label.
+		return &GPIOChip{name: "gpiochip", path: p, file: f}
+	}
+	ca, cb, cl := open(a), open(b), open(link)
+	if ca.deviceID() != cl.deviceID() {
+		t.Errorf("symlinked chip should match: %q != %q", ca.deviceID(), cl.deviceID())
+	}
+	if ca.deviceID() == cb.deviceID() {
+		t.Errorf("distinct chips should differ: %q", ca.deviceID())
+	}
+}
+
+func TestGPIOLineHaltBeforeWait(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	defer r.Close()
+	line := &GPIOLine{edge: gpio.BothEdges, direction: LineInput, fEdge: r}
+	if err := line.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	// WaitForEdge(0) would block forever if the Halt() was lost.
+	if line.WaitForEdge(0) {
+		t.Fatal("expected WaitForEdge() to be halted")
+	}
+	if line.halted.Load() {
+		t.Fatal("the halt should have been consumed")
+	}
+}
+
+func TestEdgesFrequency(t *testing.T) {
+	first := gpio_v2_line_event{Timestamp_ns: 1000, LineSeqno: 1}
+	// 100 edges over 50ms is 1kHz; one edge was dropped by the kernel.
+	last := gpio_v2_line_event{Timestamp_ns: 1000 + uint64(50*time.Millisecond), LineSeqno: 101}
+	if f := edgesFrequency(&first, &last, 100); f != physic.KiloHertz {
+		t.Errorf("edgesFrequency() = %s", f)
+	}
+	if f := edgesFrequency(&first, &first, 1); f != 0 {
+		t.Errorf("edgesFrequency() = %s", f)
+	}
+	line := &GPIOLine{}
+	if _, err := line.MeasureFrequency(0); err == nil {
+		t.Error("expected error for an invalid window")
+	}
+}
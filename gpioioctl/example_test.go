@@ -47,8 +47,8 @@ func testRotary(chip *gpioioctl.GPIOChip, stateLine, dataLine, buttonLine string
 		log.Fatal(err)
 	}
 	defer ls.Close()
-	statePinNumber := uint32(ls.ByOffset(0).Number())
-	buttonPinNumber := uint32(ls.ByOffset(2).Number())
+	statePin := ls.ByName(stateLine)
+	buttonPin := ls.ByName(buttonLine)
 
 	var tLast = time.Now().Add(-1 * time.Second)
 	var halting bool
@@ -60,14 +60,14 @@ func testRotary(chip *gpioioctl.GPIOChip, stateLine, dataLine, buttonLine string
 	}()
 	fmt.Println("Test Rotary Switch - Turn dial to test rotary encoder, press button to test it.")
 	for {
-		lineNumber, _, err := ls.WaitForEdge(0)
+		line, _, err := ls.WaitForEdge(0)
 		if err == nil {
 			tNow := time.Now()
 			if (tNow.UnixMilli() - tLast.UnixMilli()) < 100 {
 				continue
 			}
 			tLast = tNow
-			if lineNumber == statePinNumber {
+			if line == statePin {
 				var bits uint64
 				tDeadline := tNow.UnixNano() + 20_000_000
 				var consecutive uint64
@@ -90,7 +90,7 @@ func testRotary(chip *gpioioctl.GPIOChip, stateLine, dataLine, buttonLine string
 						}
 					}
 				}
-			} else if lineNumber == buttonPinNumber {
+			} else if line == buttonPin {
 				fmt.Println("Button Pressed!")
 			}
 		} else {
@@ -0,0 +1,453 @@
+//go:build gpioioctl_fake && !windows
+
+// Copyright 2026 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+//
+// This file contains an in-memory implementation of the GPIO character device
+// ioctl() calls, so code built on this package can be unit tested without
+// hardware. It is only built with the gpioioctl_fake build tag, e.g.
+// go test -tags gpioioctl_fake, so production builds never go through it.
+
+package gpioioctl
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpioreg"
+)
+
+// fakeChip holds the state of a chip created by RegisterFakeChip().
+type fakeChip struct {
+	name  string
+	lines []string
+	start time.Time
+	ino   uint64 // Inode of the pipe identifying the chip.
+
+	// Mutable, protected by fakeMu.
+	levels []gpio.Level   // Physical level of each line.
+	owners []*fakeRequest // Request holding each line, if any.
+}
+
+// fakeRequest is a line request on a fakeChip.
+//
+// The request fd handed out is the read end of a pipe, so edge events can be
+// read and waited on like the ones from the kernel.
+type fakeRequest struct {
+	fd       uintptr
+	ino      uint64 // Inode of the pipe identifying the request.
+	chip     *fakeChip
+	consumer string
+	offsets  []uint32
+	flags    []uint64
+	events   *os.File // Write end of the pipe.
+	seqno    uint32
+	lineSeq  []uint32
+}
+
+// The fake chips and requests are identified by the file descriptor handed
+// out and the inode behind it. The package closes the descriptors without
+// notifying this file, so an entry is stale once its descriptor no longer
+// refers to the same inode, and is forgotten lazily.
+var (
+	fakeMu       sync.Mutex
+	fakeChips    = map[uintptr]*fakeChip{}
+	fakeRequests = map[uintptr]*fakeRequest{}
+)
+
+// RegisterFakeChip creates an in-memory GPIO chip with one line per entry in
+// lines, adds it to Chips and registers its named lines with gpioreg.
+//
+// It is meant to unit test code built on this package without hardware and is
+// only available with the gpioioctl_fake build tag. The
+// lines support input, output, line sets and edge detection; use
+// SetFakeLevel() to change the level seen on an input line. Lines with an
+// empty name are registered as <name>-<offset>.
+//
+// Returns nil if the chip could not be created.
+func RegisterFakeChip(name string, lines []string) *GPIOChip {
+	r, w, err := syscall_pipe_wrapper()
+	if err != nil {
+		log.Printf("RegisterFakeChip(%s): %s", name, err)
+		return nil
+	}
+	// Only the read end is used to identify the chip.
+	_ = syscall_close_wrapper(w)
+	fc := &fakeChip{
+		name:   name,
+		lines:  append([]string(nil), lines...),
+		start:  time.Now(),
+		ino:    fdIno(uintptr(r)),
+		levels: make([]gpio.Level, len(lines)),
+		owners: make([]*fakeRequest, len(lines)),
+	}
+	fakeMu.Lock()
+	fakeChips[uintptr(r)] = fc
+	fakeMu.Unlock()
+	// The path exists as long as the chip is open, so Rescan() keeps it.
+	path := "/proc/self/fd/" + strconv.Itoa(r)
+	chip, err := newGPIOChipFromFile(path, os.NewFile(uintptr(r), path))
+	if err != nil {
+		log.Printf("RegisterFakeChip(%s): %s", name, err)
+		return nil
+	}
+	Chips = append(Chips, chip)
+	registered := make(map[string]struct{})
+	for _, p := range gpioreg.All() {
+		registered[p.Name()] = struct{}{}
+	}
+	chip.registerLines(registered)
+	return chip
+}
+
+// SetFakeLevel sets the level seen on line offset of a chip created with
+// RegisterFakeChip(), as if it was driven externally.
+//
+// If the line is requested with edge detection, the matching edge events are
+// generated. It fails if the line is currently requested as an output.
+func SetFakeLevel(chip *GPIOChip, offset int, l gpio.Level) error {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	fc := fakeChipLocked(chip.fd)
+	if fc == nil {
+		return fmt.Errorf("SetFakeLevel(): %s is not a fake chip", chip.Name())
+	}
+	if offset < 0 || offset >= len(fc.levels) {
+		return fmt.Errorf("SetFakeLevel(): invalid offset %d", offset)
+	}
+	req := fc.owner(uint32(offset))
+	ix := -1
+	if req != nil {
+		ix = req.index(uint32(offset))
+		if req.flags[ix]&_GPIO_V2_LINE_FLAG_OUTPUT != 0 {
+			return fmt.Errorf("SetFakeLevel(): line %d is driven as an output", offset)
+		}
+	}
+	if fc.levels[offset] == l {
+		return nil
+	}
+	fc.levels[offset] = l
+	if req != nil {
+		req.edge(fc, ix, l)
+	}
+	return nil
+}
+
+func ioctl_get_gpio_v2_line_values(fd uintptr, data *gpio_v2_line_values) error {
+	if req := fakeRequestFor(fd); req != nil {
+		return req.getValues(data)
+	}
+	return kernel_ioctl_get_gpio_v2_line_values(fd, data)
+}
+
+func ioctl_set_gpio_v2_line_values(fd uintptr, data *gpio_v2_line_values) error {
+	if req := fakeRequestFor(fd); req != nil {
+		return req.setValues(data)
+	}
+	return kernel_ioctl_set_gpio_v2_line_values(fd, data)
+}
+
+func ioctl_gpiochip_info(fd uintptr, data *gpiochip_info) error {
+	if fc := fakeChipFor(fd); fc != nil {
+		return fc.chipInfo(data)
+	}
+	return kernel_ioctl_gpiochip_info(fd, data)
+}
+
+func ioctl_gpio_v2_line_info(fd uintptr, data *gpio_v2_line_info) error {
+	if fc := fakeChipFor(fd); fc != nil {
+		return fc.lineInfo(data)
+	}
+	return kernel_ioctl_gpio_v2_line_info(fd, data)
+}
+
+func ioctl_gpio_v2_line_config(fd uintptr, data *gpio_v2_line_config) error {
+	if req := fakeRequestFor(fd); req != nil {
+		return req.setConfig(data)
+	}
+	return kernel_ioctl_gpio_v2_line_config(fd, data)
+}
+
+func ioctl_gpio_v2_line_request(fd uintptr, data *gpio_v2_line_request) error {
+	if fc := fakeChipFor(fd); fc != nil {
+		return fc.lineRequest(data)
+	}
+	return kernel_ioctl_gpio_v2_line_request(fd, data)
+}
+
+// fdIno returns the inode of the file open as fd, or 0 if fd is closed.
+func fdIno(fd uintptr) uint64 {
+	var st syscall.Stat_t
+	if syscall.Fstat(int(fd), &st) != nil {
+		return 0
+	}
+	return uint64(st.Ino)
+}
+
+func fakeChipFor(fd uintptr) *fakeChip {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	return fakeChipLocked(fd)
+}
+
+// fakeChipLocked returns the fake chip open as fd, if any. It must be called
+// with fakeMu held.
+func fakeChipLocked(fd uintptr) *fakeChip {
+	fc := fakeChips[fd]
+	if fc != nil && fc.ino != fdIno(fd) {
+		// The chip was closed.
+		delete(fakeChips, fd)
+		return nil
+	}
+	return fc
+}
+
+func fakeRequestFor(fd uintptr) *fakeRequest {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	req := fakeRequests[fd]
+	if req != nil && !req.open() {
+		req.release()
+		return nil
+	}
+	return req
+}
+
+// owner returns the request holding line offset, if any. It must be called
+// with fakeMu held.
+func (fc *fakeChip) owner(offset uint32) *fakeRequest {
+	req := fc.owners[offset]
+	if req != nil && !req.open() {
+		req.release()
+		return nil
+	}
+	return req
+}
+
+// open returns true if the request fd was not closed.
+func (req *fakeRequest) open() bool {
+	return req.ino == fdIno(req.fd)
+}
+
+// release forgets the request once its fd was closed, freeing its lines. It
+// must be called with fakeMu held.
+func (req *fakeRequest) release() {
+	if fakeRequests[req.fd] == req {
+		delete(fakeRequests, req.fd)
+	}
+	for _, offset := range req.offsets {
+		if req.chip.owners[offset] == req {
+			req.chip.owners[offset] = nil
+		}
+	}
+	_ = req.events.Close()
+}
+
+func (fc *fakeChip) chipInfo(data *gpiochip_info) error {
+	copy(data.name[:_GPIO_MAX_NAME_SIZE-1], fc.name)
+	copy(data.label[:_GPIO_MAX_NAME_SIZE-1], fc.name)
+	data.lines = uint32(len(fc.lines))
+	return nil
+}
+
+func (fc *fakeChip) lineInfo(data *gpio_v2_line_info) error {
+	if int(data.offset) >= len(fc.lines) {
+		return &IoctlError{Op: "GPIO_V2_GET_LINEINFO_IOCTL", Errno: syscall.EINVAL}
+	}
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	offset := data.offset
+	*data = gpio_v2_line_info{offset: offset, flags: _GPIO_V2_LINE_FLAG_INPUT}
+	copy(data.name[:_GPIO_MAX_NAME_SIZE-1], fc.lines[offset])
+	if req := fc.owner(offset); req != nil {
+		copy(data.consumer[:_GPIO_MAX_NAME_SIZE-1], req.consumer)
+		data.flags = req.flags[req.index(offset)] | _GPIO_V2_LINE_FLAG_USED
+	}
+	return nil
+}
+
+func (fc *fakeChip) lineRequest(data *gpio_v2_line_request) error {
+	const op = "GPIO_V2_GET_LINE_IOCTL"
+	n := int(data.num_lines)
+	if n == 0 || n > _GPIO_V2_LINES_MAX {
+		return &IoctlError{Op: op, Errno: syscall.EINVAL}
+	}
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	req := &fakeRequest{
+		chip:     fc,
+		consumer: string(data.consumer[:clen(data.consumer[:])]),
+		offsets:  append([]uint32(nil), data.offsets[:n]...),
+		flags:    make([]uint64, n),
+		lineSeq:  make([]uint32, n),
+	}
+	for i, offset := range req.offsets {
+		if int(offset) >= len(fc.lines) || req.index(offset) != i {
+			return &IoctlError{Op: op, Errno: syscall.EINVAL}
+		}
+		if fc.owner(offset) != nil {
+			return &IoctlError{Op: op, Errno: syscall.EBUSY}
+		}
+		// Lines are inputs until configured otherwise.
+		req.flags[i] = _GPIO_V2_LINE_FLAG_INPUT
+	}
+	if errno := req.configure(&data.config); errno != 0 {
+		return &IoctlError{Op: op, Errno: errno}
+	}
+	r, w, err := syscall_pipe_wrapper()
+	if err != nil {
+		return &IoctlError{Op: op, Errno: syscall.ENOMEM}
+	}
+	req.events = os.NewFile(uintptr(w), "gpio-fake-events")
+	req.fd = uintptr(r)
+	req.ino = fdIno(req.fd)
+	if old := fakeRequests[req.fd]; old != nil {
+		// The fd of a closed request was reused.
+		old.release()
+	}
+	for _, offset := range req.offsets {
+		fc.owners[offset] = req
+	}
+	fakeRequests[req.fd] = req
+	data.fd = int32(r)
+	return nil
+}
+
+// index returns the index of offset in the request, or -1.
+func (req *fakeRequest) index(offset uint32) int {
+	for i, o := range req.offsets {
+		if o == offset {
+			return i
+		}
+	}
+	return -1
+}
+
+// configure applies cfg to the lines of the request. It must be called with
+// fakeMu held.
+func (req *fakeRequest) configure(cfg *gpio_v2_line_config) syscall.Errno {
+	if cfg.num_attrs > _GPIO_V2_LINE_NUM_ATTRS_MAX {
+		return syscall.EINVAL
+	}
+	flags := make([]uint64, len(req.offsets))
+	var values uint64
+	for i := range req.offsets {
+		flags[i] = cfg.flags
+		for _, a := range cfg.attrs[:cfg.num_attrs] {
+			if a.mask&(uint64(1)<<i) == 0 {
+				continue
+			}
+			switch a.attr.id {
+			case _GPIO_V2_LINE_ATTR_ID_FLAGS:
+				flags[i] = a.attr.value
+			case _GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES:
+				values |= a.attr.value & (uint64(1) << i)
+			}
+		}
+		const dir = _GPIO_V2_LINE_FLAG_INPUT | _GPIO_V2_LINE_FLAG_OUTPUT
+		const edges = _GPIO_V2_LINE_FLAG_EDGE_RISING | _GPIO_V2_LINE_FLAG_EDGE_FALLING
+		if flags[i]&dir == dir || (flags[i]&_GPIO_V2_LINE_FLAG_OUTPUT != 0 && flags[i]&edges != 0) {
+			return syscall.EINVAL
+		}
+	}
+	for i, offset := range req.offsets {
+		if flags[i] == 0 {
+			// Left as is.
+			continue
+		}
+		req.flags[i] = flags[i]
+		if flags[i]&_GPIO_V2_LINE_FLAG_OUTPUT != 0 {
+			req.chip.levels[offset] = req.physical(i, values&(uint64(1)<<i) != 0)
+		}
+	}
+	return 0
+}
+
+// physical converts between the logical and the physical level of line i.
+func (req *fakeRequest) physical(i int, l gpio.Level) gpio.Level {
+	if req.flags[i]&_GPIO_V2_LINE_FLAG_ACTIVE_LOW != 0 {
+		return !l
+	}
+	return l
+}
+
+// edge generates the edge event, if enabled, after line i changed to the
+// physical level l. It must be called with fakeMu held.
+func (req *fakeRequest) edge(fc *fakeChip, i int, l gpio.Level) {
+	id := _GPIO_V2_LINE_EVENT_FALLING_EDGE
+	enabled := _GPIO_V2_LINE_FLAG_EDGE_FALLING
+	if req.physical(i, l) {
+		id = _GPIO_V2_LINE_EVENT_RISING_EDGE
+		enabled = _GPIO_V2_LINE_FLAG_EDGE_RISING
+	}
+	if req.flags[i]&enabled == 0 {
+		return
+	}
+	req.seqno++
+	req.lineSeq[i]++
+	event := gpio_v2_line_event{
+		Timestamp_ns: uint64(time.Since(fc.start)),
+		Id:           id,
+		Offset:       req.offsets[i],
+		Seqno:        req.seqno,
+		LineSeqno:    req.lineSeq[i],
+	}
+	// The reader may have gone away; there's nobody to report the error to.
+	_ = binary.Write(req.events, binary.LittleEndian, &event)
+}
+
+func (req *fakeRequest) getValues(data *gpio_v2_line_values) error {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	var bits uint64
+	for i, offset := range req.offsets {
+		if data.mask&(uint64(1)<<i) != 0 && req.physical(i, req.chip.levels[offset]) {
+			bits |= uint64(1) << i
+		}
+	}
+	data.bits = bits
+	return nil
+}
+
+func (req *fakeRequest) setValues(data *gpio_v2_line_values) error {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	for i := range req.offsets {
+		if data.mask&(uint64(1)<<i) != 0 && req.flags[i]&_GPIO_V2_LINE_FLAG_OUTPUT == 0 {
+			return &IoctlError{Op: "GPIO_V2_LINE_SET_VALUES_IOCTL", Errno: syscall.EPERM}
+		}
+	}
+	for i, offset := range req.offsets {
+		if data.mask&(uint64(1)<<i) != 0 {
+			req.chip.levels[offset] = req.physical(i, data.bits&(uint64(1)<<i) != 0)
+		}
+	}
+	return nil
+}
+
+func (req *fakeRequest) setConfig(data *gpio_v2_line_config) error {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	if errno := req.configure(data); errno != 0 {
+		return &IoctlError{Op: "GPIO_V2_LINE_SET_CONFIG_IOCTL", Errno: errno}
+	}
+	return nil
+}
+
+// clen returns the length of the NUL terminated string in b.
+func clen(b []byte) int {
+	for i, c := range b {
+		if c == 0 {
+			return i
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,618 @@
+//go:build gpioioctl_fake && !windows
+
+// Copyright 2026 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpioioctl
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/conn/v3/pin"
+)
+
+// newFakeChip registers a fake chip for the duration of the test.
+func newFakeChip(t *testing.T, name string, lines ...string) *GPIOChip {
+	t.Helper()
+	chip := RegisterFakeChip(name, lines)
+	if chip == nil {
+		t.Fatal("RegisterFakeChip() failed")
+	}
+	t.Cleanup(func() {
+		for i, l := range lines {
+			if l == "" {
+				l = name + "-" + strconv.Itoa(i)
+			}
+			_ = gpioreg.Unregister(l)
+		}
+		if i := slices.Index(Chips, chip); i != -1 {
+			Chips = slices.Delete(slices.Clone(Chips), i, i+1)
+		}
+		_ = chip.Close()
+	})
+	return chip
+}
+
+func TestFakeChip(t *testing.T) {
+	chip := newFakeChip(t, "fakechip0", "FAKE_IN", "FAKE_OUT", "")
+	if chip.Name() != "fakechip0" || chip.LineCount() != 3 {
+		t.Fatalf("unexpected chip %s", chip)
+	}
+	in := gpioreg.ByName("FAKE_IN")
+	if in == nil {
+		t.Fatal("FAKE_IN is not registered")
+	}
+
+	// Unnamed lines are registered under a synthesized name.
+	if p := gpioreg.ByName("fakechip0-2"); p == nil || p.Number() != 2 {
+		t.Fatalf("fakechip0-2 = %v", p)
+	}
+
+	out := chip.ByName("FAKE_OUT")
+	if err := out.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if SetFakeLevel(chip, 1, gpio.Low) == nil {
+		t.Fatal("expected error setting the level of an output")
+	}
+	// Read() turns the line into an input, the level is retained.
+	if out.Read() != gpio.High {
+		t.Fatal("expected High")
+	}
+	if err := SetFakeLevel(chip, 1, gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.In(gpio.PullNoChange, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	if in.WaitForEdge(time.Millisecond) {
+		t.Fatal("unexpected edge")
+	}
+	if err := SetFakeLevel(chip, 0, gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if !in.WaitForEdge(time.Second) {
+		t.Fatal("expected rising edge")
+	}
+	if in.Read() != gpio.High {
+		t.Fatal("expected input to read High")
+	}
+	// Falling edges are not enabled.
+	if err := SetFakeLevel(chip, 0, gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if in.WaitForEdge(time.Millisecond) {
+		t.Fatal("unexpected falling edge")
+	}
+
+	// The lines are busy until closed.
+	_, err := chip.LineSet(LineInput, gpio.BothEdges, gpio.PullNoChange, "FAKE_IN", "FAKE_OUT")
+	if !errors.Is(err, syscall.EBUSY) {
+		t.Fatalf("expected EBUSY, got %v", err)
+	}
+	if err := chip.ByName("FAKE_IN").Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ls, err := chip.LineSet(LineInput, gpio.BothEdges, gpio.PullNoChange, "FAKE_IN", "FAKE_OUT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFakeLevel(chip, 1, gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	line, edge, err := ls.WaitForEdge(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line == nil || line.Name() != "FAKE_OUT" || edge != gpio.RisingEdge {
+		t.Fatalf("WaitForEdge() = %s, %s", line, edge)
+	}
+	if bits, err := ls.Read(0); err != nil || bits != 0b10 {
+		t.Fatalf("Read() = %#b, %v", bits, err)
+	}
+}
+
+func TestLineLifecycle(t *testing.T) {
+	chip := newFakeChip(t, "fakechip1", "LIFE0")
+	line := chip.ByName("LIFE0")
+	if err := line.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := line.Close(); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+	}
+	// The line is requested again on reuse.
+	if err := line.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := chip.Close(); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+	}
+	if err := line.Out(gpio.High); !errors.Is(err, errChipClosed) {
+		t.Fatalf("expected errChipClosed, got %v", err)
+	}
+	if err := line.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLineSetDrainEvents(t *testing.T) {
+	chip := newFakeChip(t, "fakechip2", "DRAIN0", "DRAIN1")
+	ls, err := chip.LineSet(LineInput, gpio.BothEdges, gpio.PullNoChange, "DRAIN0", "DRAIN1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ls.DrainEvents(0, time.Millisecond); err == nil {
+		t.Fatal("expected error")
+	}
+	changes := []struct {
+		offset int
+		l      gpio.Level
+	}{{1, gpio.High}, {0, gpio.High}, {1, gpio.Low}}
+	for _, c := range changes {
+		if err := SetFakeLevel(chip, c.offset, c.l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := ls.DrainEvents(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Number != 1 || events[1].Number != 0 || events[1].Seqno != 2 {
+		t.Fatalf("%+v", events)
+	}
+	events, err = ls.DrainEvents(10, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Number != 1 || events[0].Edge != gpio.FallingEdge {
+		t.Fatalf("%+v", events)
+	}
+}
+
+func TestExportConfig(t *testing.T) {
+	chip := newFakeChip(t, "fakechip3", "EXP0", "EXP1", "EXP2", "EXP3")
+	if err := chip.ByName("EXP0").Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if err := chip.ByName("EXP1").In(gpio.PullUp, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	cfgs, err := chip.ExportConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*LineSetConfig{{
+		Lines:            []string{"EXP0", "EXP1", "EXP2", "EXP3"},
+		DefaultDirection: LineInput,
+		Overrides: []*LineConfigOverride{
+			{Lines: []string{"EXP0"}, Direction: LineOutput},
+			{Lines: []string{"EXP1"}, Direction: LineInput, Edge: gpio.RisingEdge, Pull: gpio.PullUp},
+		},
+	}}
+	if !reflect.DeepEqual(cfgs, want) {
+		t.Fatalf("got %+v, want %+v", cfgs, want)
+	}
+
+	// Reapplying the configuration reproduces it.
+	for _, line := range chip.Lines() {
+		if err := line.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ls, err := chip.LineSetFromConfig(cfgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Close()
+	got, err := chip.ExportConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestExportConfig_manyLines(t *testing.T) {
+	names := make([]string, _GPIO_V2_LINES_MAX+6)
+	for i := range names {
+		names[i] = "MANY" + strconv.Itoa(i)
+	}
+	chip := newFakeChip(t, "fakechip15", names...)
+	cfgs, err := chip.ExportConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 2 || len(cfgs[0].Lines) != _GPIO_V2_LINES_MAX || len(cfgs[1].Lines) != 6 {
+		t.Fatalf("%+v", cfgs)
+	}
+	if cfgs[1].Lines[0] != names[_GPIO_V2_LINES_MAX] {
+		t.Fatal(cfgs[1].Lines)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	saved := Chips
+	defer func() { Chips = saved }()
+	chip := newFakeChip(t, "fakechip4", "DOWN0", "DOWN1", "DOWN2")
+	Chips = []*GPIOChip{chip}
+	line := chip.ByName("DOWN0")
+	if err := line.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	ls, err := chip.LineSet(LineOutput, gpio.NoEdge, gpio.PullNoChange, "DOWN1", "DOWN2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sets := chip.LineSets(); len(sets) != 1 || sets[0] != ls {
+		t.Fatalf("LineSets() = %v", sets)
+	}
+	if err := Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if len(Chips) != 0 {
+		t.Fatalf("Chips = %v", Chips)
+	}
+	if len(chip.LineSets()) != 0 {
+		t.Fatal("expected the line sets to be released")
+	}
+	for _, name := range []string{"DOWN0", "DOWN1", "DOWN2"} {
+		if gpioreg.ByName(name) != nil {
+			t.Fatalf("%s is still registered", name)
+		}
+	}
+	if err := line.Out(gpio.Low); !errors.Is(err, errChipClosed) {
+		t.Fatalf("expected errChipClosed, got %v", err)
+	}
+	if err := Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvertedLine(t *testing.T) {
+	chip := newFakeChip(t, "fakechip5", "INV0")
+	line := chip.ByName("INV0")
+	inv := InvertedLine(line)
+	if err := inv.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	fakeMu.Lock()
+	l := fakeChips[chip.fd].levels[0]
+	fakeMu.Unlock()
+	if l != gpio.Low {
+		t.Fatal("expected the line to be driven low")
+	}
+	if f := inv.(pin.PinFunc).Func(); f != gpio.OUT_HIGH {
+		t.Fatalf("Func() = %s", f)
+	}
+
+	// A rising edge of the inverted line is a falling edge of the line.
+	if err := SetFakeLevel(chip, 0, gpio.High); err == nil {
+		t.Fatal("expected error setting the level of an output")
+	}
+	if err := inv.In(gpio.PullNoChange, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFakeLevel(chip, 0, gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if inv.WaitForEdge(time.Millisecond) {
+		t.Fatal("unexpected edge")
+	}
+	if inv.Read() != gpio.Low || line.Read() != gpio.High {
+		t.Fatal("expected the inverted line to read Low")
+	}
+	if err := SetFakeLevel(chip, 0, gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if !inv.WaitForEdge(time.Second) {
+		t.Fatal("expected rising edge")
+	}
+	if inv.Read() != gpio.High {
+		t.Fatal("expected the inverted line to read High")
+	}
+}
+
+func TestGPIOLineToggle(t *testing.T) {
+	chip := newFakeChip(t, "fakechip6", "TOGGLE0")
+	line := chip.ByName("TOGGLE0")
+	if _, err := line.Toggle(); err == nil {
+		t.Fatal("expected error toggling a line not configured as output")
+	}
+	if err := line.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []gpio.Level{gpio.High, gpio.Low, gpio.High} {
+		l, err := line.Toggle()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fakeMu.Lock()
+		physical := fakeChips[chip.fd].levels[0]
+		fakeMu.Unlock()
+		if l != want || physical != want {
+			t.Fatalf("Toggle() = %s, line is %s; want %s", l, physical, want)
+		}
+	}
+}
+
+func TestGPIOLineWaitForEvent(t *testing.T) {
+	chip := newFakeChip(t, "fakechip7", "EVENT0")
+	line := chip.ByName("EVENT0")
+	if _, _, err := line.WaitForEvent(time.Millisecond); err == nil {
+		t.Fatal("expected error, edge detection is not configured")
+	}
+	if err := line.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := line.WaitForEvent(time.Millisecond); err == nil {
+		t.Fatal("expected timeout")
+	}
+	start := time.Now()
+	for _, want := range []gpio.Edge{gpio.RisingEdge, gpio.FallingEdge} {
+		if err := SetFakeLevel(chip, 0, want == gpio.RisingEdge); err != nil {
+			t.Fatal(err)
+		}
+		edge, ts, err := line.WaitForEvent(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if edge != want || ts.Before(start) {
+			t.Fatalf("WaitForEvent() = %s, %s; want %s", edge, ts, want)
+		}
+	}
+	if err := line.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := line.WaitForEvent(0); err == nil {
+		t.Fatal("expected halt")
+	}
+}
+
+func TestRescan(t *testing.T) {
+	saved := Chips
+	defer func() { Chips = saved }()
+	fake := newFakeChip(t, "fakechip8", "RESCAN0")
+	gone := &GPIOChip{name: "gpiochip99", path: t.TempDir() + "/gpiochip99"}
+	gone.lines = []*GPIOLine{newGPIOLine(0, "GONE0", "", 0)}
+	gone.registerLines(map[string]struct{}{})
+	Chips = []*GPIOChip{gone, fake}
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	for _, chip := range Chips {
+		if chip == gone {
+			t.Fatal("expected the vanished chip to be removed")
+		}
+	}
+	if len(Chips) == 0 || Chips[0] != fake {
+		t.Fatalf("expected the fake chip to be kept: %v", Chips)
+	}
+	if gpioreg.ByName("GONE0") != nil {
+		t.Fatal("expected GONE0 to be unregistered")
+	}
+}
+
+func TestLineSetOutOrdered(t *testing.T) {
+	chip := newFakeChip(t, "fakechip9", "BUS0", "BUS1", "BUS2")
+	ls, err := chip.LineSet(LineOutput, gpio.NoEdge, gpio.PullNoChange, "BUS2", "BUS0", "BUS1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.OutOrdered(make([]gpio.Level, 4)); err == nil {
+		t.Fatal("expected error, too many levels")
+	}
+	if err := ls.OutOrdered([]gpio.Level{gpio.High, gpio.Low, gpio.High}); err != nil {
+		t.Fatal(err)
+	}
+	// Only BUS2 is changed.
+	if err := ls.OutOrdered([]gpio.Level{gpio.Low}); err != nil {
+		t.Fatal(err)
+	}
+	fakeMu.Lock()
+	got := append([]gpio.Level(nil), fakeChips[chip.fd].levels...)
+	fakeMu.Unlock()
+	if want := []gpio.Level{gpio.Low, gpio.High, gpio.Low}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("levels = %v; want %v", got, want)
+	}
+}
+
+func TestLineSetWaitForEdgeDebounced(t *testing.T) {
+	chip := newFakeChip(t, "fakechip10", "DEB0")
+	ls, err := chip.LineSet(LineInput, gpio.BothEdges, gpio.PullNoChange, "DEB0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ls.WaitForEdgeDebounced(0, time.Millisecond); err == nil {
+		t.Fatal("expected error, invalid settle time")
+	}
+	// Bouncing before settling high.
+	for _, l := range []gpio.Level{gpio.High, gpio.Low, gpio.High} {
+		if err := SetFakeLevel(chip, 0, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	line, edge, err := ls.WaitForEdgeDebounced(10*time.Millisecond, time.Second)
+	if err != nil || line != ls.ByName("DEB0") || edge != gpio.RisingEdge {
+		t.Fatalf("WaitForEdgeDebounced() = %s, %s, %v", line, edge, err)
+	}
+	// A glitch settling back high is ignored.
+	for _, l := range []gpio.Level{gpio.Low, gpio.High} {
+		if err := SetFakeLevel(chip, 0, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, edge, err := ls.WaitForEdgeDebounced(10*time.Millisecond, 50*time.Millisecond); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("WaitForEdgeDebounced() = %s, %v", edge, err)
+	}
+}
+
+func TestGPIOLineRefreshInfo(t *testing.T) {
+	chip := newFakeChip(t, "fakechip11", "KINFO0")
+	line := chip.ByName("KINFO0")
+	if d, p := line.Direction(), line.KernelPull(); d != LineInput || p != gpio.PullNoChange {
+		t.Fatalf("Direction() = %s, KernelPull() = %s", DirectionLabels[d], p)
+	}
+	// Another request configures the line, like another process would.
+	ls, err := chip.LineSet(LineOutput, gpio.NoEdge, gpio.PullUp, "KINFO0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Close()
+	if err := line.RefreshInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if d, p := line.Direction(), line.KernelPull(); d != LineOutput || p != gpio.PullUp {
+		t.Fatalf("Direction() = %s, KernelPull() = %s", DirectionLabels[d], p)
+	}
+	if line.Consumer() == "" {
+		t.Fatal("expected the consumer to be reported")
+	}
+	// The configuration done by this process is unaffected.
+	if line.Pull() != gpio.PullNoChange {
+		t.Fatal(line.Pull())
+	}
+}
+
+func TestClaimLine(t *testing.T) {
+	chip := newFakeChip(t, "fakechip12", "CLAIM0", "CLAIM1")
+	if _, err := chip.ClaimLine("CLAIM0", LineConfig{Direction: LineOutput, Edge: gpio.RisingEdge}); err == nil {
+		t.Fatal("expected error, edge detection on an output")
+	}
+	var notFound *LineNotFoundError
+	if _, err := chip.ClaimLine("CLAIM2", LineConfig{Direction: LineInput}); !errors.As(err, &notFound) {
+		t.Fatal(err)
+	}
+	line, err := chip.ClaimLine("CLAIM0", LineConfig{Direction: LineOutput, Level: gpio.High})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeMu.Lock()
+	l := fakeChips[chip.fd].levels[0]
+	fakeMu.Unlock()
+	if l != gpio.High || line.Consumer() == "" {
+		t.Fatalf("level = %s, consumer = %q", l, line.Consumer())
+	}
+	// Claiming again reconfigures the line.
+	if _, err := chip.ClaimLine("CLAIM0", LineConfig{Direction: LineInput, Pull: gpio.PullUp}); err != nil {
+		t.Fatal(err)
+	}
+	if line.Pull() != gpio.PullUp {
+		t.Fatal(line.Pull())
+	}
+	// A line in use by another consumer fails at claim time.
+	ls, err := chip.LineSet(LineInput, gpio.NoEdge, gpio.PullNoChange, "CLAIM1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Close()
+	if _, err := chip.ClaimLine("CLAIM1", LineConfig{Direction: LineInput}); !errors.Is(err, syscall.EBUSY) {
+		t.Fatal(err)
+	}
+}
+
+func TestLineSetReconfigure(t *testing.T) {
+	chip := newFakeChip(t, "fakechip13", "RECFG0", "RECFG1", "RECFG2")
+	cfg := &LineSetConfig{
+		Lines:            []string{"RECFG0", "RECFG1", "RECFG2"},
+		DefaultDirection: LineInput,
+		OutputValues:     map[string]gpio.Level{"RECFG2": gpio.High},
+	}
+	if err := cfg.AddOverrides(LineOutput, gpio.NoEdge, gpio.PullNoChange, "RECFG2"); err != nil {
+		t.Fatal(err)
+	}
+	ls, err := chip.LineSetFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ls.Reconfigure(&LineSetConfig{Lines: []string{"RECFG1", "RECFG0", "RECFG2"}}) == nil {
+		t.Fatal("expected error, lines in a different order")
+	}
+	// RECFG0 becomes an output driven high, RECFG2 stays an output and keeps
+	// its level.
+	next := &LineSetConfig{
+		Lines:            cfg.Lines,
+		DefaultDirection: LineOutput,
+		OutputValues:     map[string]gpio.Level{"RECFG0": gpio.High},
+	}
+	if err := next.AddOverrides(LineInput, gpio.BothEdges, gpio.PullUp, "RECFG1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.Reconfigure(next); err != nil {
+		t.Fatal(err)
+	}
+	fakeMu.Lock()
+	got := append([]gpio.Level(nil), fakeChips[chip.fd].levels...)
+	fakeMu.Unlock()
+	if want := []gpio.Level{gpio.High, gpio.Low, gpio.High}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("levels = %v; want %v", got, want)
+	}
+	if l := ls.ByName("RECFG0"); l.Direction() != LineOutput {
+		t.Fatal(DirectionLabels[l.Direction()])
+	}
+	if l := ls.ByName("RECFG1"); l.Direction() != LineInput || l.Edge() != gpio.BothEdges || l.Pull() != gpio.PullUp {
+		t.Fatalf("%s", l)
+	}
+}
+
+func TestLineSetLineSetFunc_reconfigure(t *testing.T) {
+	chip := newFakeChip(t, "fakechip14", "FUNC0", "FUNC1", "FUNC2")
+	cfg := &LineSetConfig{
+		Lines:            []string{"FUNC0", "FUNC1", "FUNC2"},
+		DefaultDirection: LineOutput,
+		OutputValues:     map[string]gpio.Level{"FUNC2": gpio.High},
+	}
+	if err := cfg.AddOverrides(LineInput, gpio.BothEdges, gpio.PullUp, "FUNC1"); err != nil {
+		t.Fatal(err)
+	}
+	ls, err := chip.LineSetFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The output becomes an input.
+	if err := ls.ByName("FUNC0").SetFunc(gpio.IN); err != nil {
+		t.Fatal(err)
+	}
+	// The input becomes an output driven high.
+	if err := ls.ByName("FUNC1").SetFunc(gpio.OUT_HIGH); err != nil {
+		t.Fatal(err)
+	}
+	if l := ls.ByName("FUNC0"); l.Direction() != LineInput {
+		t.Fatal(DirectionLabels[l.Direction()])
+	}
+	if l := ls.ByName("FUNC1"); l.Direction() != LineOutput || l.Edge() != gpio.NoEdge {
+		t.Fatalf("%s", l)
+	}
+	fakeMu.Lock()
+	got := append([]gpio.Level(nil), fakeChips[chip.fd].levels...)
+	fakeMu.Unlock()
+	// FUNC2 keeps driving its level.
+	if want := []gpio.Level{gpio.Low, gpio.High, gpio.High}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("levels = %v; want %v", got, want)
+	}
+	if err := ls.ByName("FUNC0").In(gpio.PullNoChange, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	if l := ls.ByName("FUNC0"); l.Edge() != gpio.RisingEdge {
+		t.Fatalf("%s", l)
+	}
+}
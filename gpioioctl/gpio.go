@@ -5,6 +5,7 @@ package gpioioctl
 // that can be found in the LICENSE file.
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
@@ -14,9 +15,13 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"periph.io/x/conn/v3/driver/driverreg"
@@ -41,6 +46,19 @@ var consumer []byte
 // The set of GPIO Chips found on the running device.
 var Chips []*GPIOChip
 
+// The label of the chip to sort first in Chips. Set by SetPreferredChip().
+var preferredChip string
+
+// SetPreferredChip sets the label of the chip that should be sorted first in
+// Chips, so Chips[0] is deterministic on boards exposing multiple chips, like
+// the Raspberry Pi 5.
+//
+// It must be called before host.Init(). An empty label restores the default
+// ordering.
+func SetPreferredChip(label string) {
+	preferredChip = label
+}
+
 type Label string
 
 var DirectionLabels = []Label{"NotSet", "Input", "Output"}
@@ -63,10 +81,18 @@ type GPIOLine struct {
 	edge      gpio.Edge
 	pull      gpio.Pull
 	direction LineDir
+	level     gpio.Level // Cache of the last level written or read.
 	mu        sync.Mutex
 	chip_fd   uintptr
 	fd        int32
 	fEdge     *os.File
+	// halted is set by Halt() and consumed by the next wait, so a Halt() that
+	// races ahead of WaitForEdge() is not lost.
+	halted atomic.Bool
+	// kernelFlags are the flags reported by the kernel as of the last line
+	// info read, which reflect the configuration of whichever process
+	// requested the line.
+	kernelFlags uint64
 }
 
 func newGPIOLine(lineNum uint32, name string, consumer string, fd uintptr) *GPIOLine {
@@ -80,21 +106,33 @@ func newGPIOLine(lineNum uint32, name string, consumer string, fd uintptr) *GPIO
 	return &line
 }
 
-// Close the line, and any associated files/file descriptors that were created.
-func (line *GPIOLine) Close() {
+// Close releases the line, and any associated files/file descriptors that
+// were created, so it can be requested by another consumer.
+//
+// It is safe to call Close() multiple times. The line is requested again on
+// its next use, as long as the chip is not closed.
+func (line *GPIOLine) Close() error {
 	line.mu.Lock()
 	defer line.mu.Unlock()
+	return line.closeLocked()
+}
+
+// closeLocked is the locked version of Close.
+func (line *GPIOLine) closeLocked() error {
+	var err error
 	if line.fEdge != nil {
-		_ = line.fEdge.Close()
+		err = line.fEdge.Close()
 	} else if line.fd != 0 {
-		_ = syscall_close_wrapper(int(line.fd))
+		err = syscall_close_wrapper(int(line.fd))
 	}
 	line.fd = 0
 	line.consumer = ""
 	line.edge = gpio.NoEdge
 	line.direction = LineDirNotSet
 	line.pull = gpio.PullNoChange
+	line.level = gpio.Low
 	line.fEdge = nil
+	return err
 }
 
 // Consumer returns the name of the consumer specified for a line when
@@ -104,6 +142,43 @@ func (line *GPIOLine) Consumer() string {
 	return line.consumer
 }
 
+// RefreshInfo reads the line information from the kernel again, updating
+// Consumer(), Direction() and KernelPull().
+//
+// This permits to inspect a line requested by another process.
+func (line *GPIOLine) RefreshInfo() error {
+	info := gpio_v2_line_info{offset: line.number}
+	if err := ioctl_gpio_v2_line_info(line.chip_fd, &info); err != nil {
+		return fmt.Errorf("GPIOLine.RefreshInfo(): %w", err)
+	}
+	line.mu.Lock()
+	defer line.mu.Unlock()
+	line.consumer = strings.Trim(string(info.consumer[:]), "\x00")
+	line.kernelFlags = info.flags
+	return nil
+}
+
+// Direction returns the direction of the line as reported by the kernel when
+// the chip was opened or on the last RefreshInfo() call, regardless of the
+// process that configured it.
+func (line *GPIOLine) Direction() LineDir {
+	line.mu.Lock()
+	defer line.mu.Unlock()
+	dir, _, _ := parseFlags(line.kernelFlags)
+	return dir
+}
+
+// KernelPull returns the bias of the line as reported by the kernel when the
+// chip was opened or on the last RefreshInfo() call, regardless of the
+// process that configured it. Unlike Pull(), it doesn't only reflect the
+// configuration done by this process.
+func (line *GPIOLine) KernelPull() gpio.Pull {
+	line.mu.Lock()
+	defer line.mu.Unlock()
+	_, _, pull := parseFlags(line.kernelFlags)
+	return pull
+}
+
 // DefaultPull - return gpio.PullNoChange. Reviewing the GPIO v2 Kernel IOCTL docs, this isn't possible.
 func (line *GPIOLine) DefaultPull() gpio.Pull {
 	return gpio.PullNoChange
@@ -111,6 +186,7 @@ func (line *GPIOLine) DefaultPull() gpio.Pull {
 
 // Halt interrupts a pending WaitForEdge() command.
 func (line *GPIOLine) Halt() error {
+	line.halted.Store(true)
 	if line.fEdge != nil {
 		return line.fEdge.SetReadDeadline(time.UnixMilli(0))
 	}
@@ -118,7 +194,13 @@ func (line *GPIOLine) Halt() error {
 }
 
 // Configure the GPIOLine for input. Implements gpio.PinIn.
+//
+// gpio.PullNoChange leaves the bias of the pad as-is, while gpio.Float
+// disables it.
 func (line *GPIOLine) In(pull gpio.Pull, edge gpio.Edge) error {
+	if err := validateFlags(LineInput, edge, pull); err != nil {
+		return fmt.Errorf("GPIOLine.In(): %w", err)
+	}
 	line.mu.Lock()
 	defer line.mu.Unlock()
 	flags := getFlags(LineInput, edge, pull)
@@ -154,7 +236,94 @@ func (line *GPIOLine) Out(l gpio.Level) error {
 	if l {
 		data.bits = 0x01
 	}
-	return ioctl_set_gpio_v2_line_values(uintptr(line.fd), &data)
+	if err := ioctl_set_gpio_v2_line_values(uintptr(line.fd), &data); err != nil {
+		return err
+	}
+	line.level = l
+	return nil
+}
+
+// OutFast sets the line level without locking and without checking the line
+// direction, to maximize the toggle rate, e.g. for performance tests.
+//
+// The line must already be configured as an output, e.g. with a prior call to
+// Out(). It is not safe for concurrent use with other methods of this line.
+func (line *GPIOLine) OutFast(l gpio.Level) error {
+	data := gpio_v2_line_values{mask: 0x01}
+	if l {
+		data.bits = 0x01
+	}
+	if err := ioctl_set_gpio_v2_line_values(uintptr(line.fd), &data); err != nil {
+		return err
+	}
+	line.level = l
+	return nil
+}
+
+// Toggle inverts the level last written to an output line with a single
+// set-values ioctl and returns the new level.
+//
+// It fails if the line is not configured as an output.
+func (line *GPIOLine) Toggle() (gpio.Level, error) {
+	line.mu.Lock()
+	defer line.mu.Unlock()
+	if line.direction != LineOutput {
+		return line.level, errors.New("GPIOLine.Toggle(): line is not an output")
+	}
+	l := !line.level
+	data := gpio_v2_line_values{mask: 0x01}
+	if l {
+		data.bits = 0x01
+	}
+	if err := ioctl_set_gpio_v2_line_values(uintptr(line.fd), &data); err != nil {
+		return line.level, fmt.Errorf("GPIOLine.Toggle(): %w", err)
+	}
+	line.level = l
+	return l, nil
+}
+
+// Pulse drives count pulses on the line, each high for highDur then low for
+// lowDur, and leaves the line low. The line is configured as an output if
+// needed.
+//
+// It busy-loops between set-values ioctls on an absolute schedule, so errors
+// don't accumulate over the pulse train, but each edge still has jitter: the
+// ioctl itself takes a few µs, and the goroutine can be preempted or
+// descheduled by the OS for up to milliseconds. It is not suitable for
+// periods shorter than ~10µs or for timing critical protocols; use a
+// hardware PWM or a dedicated peripheral for that. It keeps a CPU busy for the
+// whole duration.
+func (line *GPIOLine) Pulse(count int, highDur, lowDur time.Duration) error {
+	if count < 0 || highDur < 0 || lowDur < 0 {
+		return errors.New("GPIOLine.Pulse(): count and durations must be positive")
+	}
+	line.mu.Lock()
+	defer line.mu.Unlock()
+	if line.direction != LineOutput {
+		if err := line.setOut(); err != nil {
+			return fmt.Errorf("GPIOLine.Pulse(): %w", err)
+		}
+	}
+	high := gpio_v2_line_values{bits: 0x01, mask: 0x01}
+	low := gpio_v2_line_values{mask: 0x01}
+	next := time.Now()
+	for i := 0; i < count; i++ {
+		if err := ioctl_set_gpio_v2_line_values(uintptr(line.fd), &high); err != nil {
+			return fmt.Errorf("GPIOLine.Pulse(): %w", err)
+		}
+		line.level = gpio.High
+		next = next.Add(highDur)
+		for time.Now().Before(next) {
+		}
+		if err := ioctl_set_gpio_v2_line_values(uintptr(line.fd), &low); err != nil {
+			return fmt.Errorf("GPIOLine.Pulse(): %w", err)
+		}
+		line.level = gpio.Low
+		next = next.Add(lowDur)
+		for time.Now().Before(next) {
+		}
+	}
+	return nil
 }
 
 // Pull returns the configured Line Bias.
@@ -186,9 +355,12 @@ func (line *GPIOLine) Read() gpio.Level {
 		log.Println(err)
 		return false
 	}
-	return data.bits&0x01 == 0x01
+	line.level = data.bits&0x01 == 0x01
+	return line.level
 }
 
+// MarshalJSON returns the line information in JSON format. It reports the
+// cached direction and level, and never queries the hardware.
 func (line *GPIOLine) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		Line      int    `json:"Line"`
@@ -197,16 +369,20 @@ func (line *GPIOLine) MarshalJSON() ([]byte, error) {
 		Direction Label  `json:"Direction"`
 		Pull      Label  `json:"Pull"`
 		Edges     Label  `json:"Edges"`
+		Level     string `json:"Level"`
 	}{
 		Line:      line.Number(),
 		Name:      line.Name(),
 		Consumer:  line.Consumer(),
 		Direction: DirectionLabels[line.direction],
 		Pull:      PullLabels[line.pull],
-		Edges:     EdgeLabels[line.edge]})
+		Edges:     EdgeLabels[line.edge],
+		Level:     line.level.String()})
 }
 
-// String returns information about the line in valid JSON format.
+// String returns information about the line in valid JSON format. Like
+// MarshalJSON, it never queries the hardware, so it is safe to use when
+// logging.
 func (line *GPIOLine) String() string {
 	json, _ := json.MarshalIndent(line, "", "    ")
 	return string(json)
@@ -217,40 +393,167 @@ func (line *GPIOLine) String() string {
 // Implements gpio.PinIn.
 //
 // Note that this does not return which edge was detected for the
-// gpio.EdgeBoth configuration. If you really need the edge,
-// LineSet.WaitForEdge() does return the edge that triggered.
+// gpio.EdgeBoth configuration. If you really need the edge, use
+// WaitForEvent().
 //
 // timeout for the edge change to occur. If 0, waits forever.
 func (line *GPIOLine) WaitForEdge(timeout time.Duration) bool {
-	if line.edge == gpio.NoEdge || line.direction == LineDirNotSet {
-		log.Println("call to WaitForEdge() when line hasn't been configured for edge detection.")
+	return line.waitForEdge(context.Background(), timeout)
+}
+
+// WaitForEdgeContext waits for this line to trigger an edge event, like
+// WaitForEdge(), until ctx is done. It returns false if ctx is canceled or
+// its deadline expires before an edge is detected.
+func (line *GPIOLine) WaitForEdgeContext(ctx context.Context) bool {
+	return line.waitForEdge(ctx, 0)
+}
+
+func (line *GPIOLine) waitForEdge(ctx context.Context, timeout time.Duration) bool {
+	if err := line.prepareWait(timeout); err != nil {
+		if err != errHalted {
+			log.Println(err)
+		}
 		return false
 	}
-	var err error
-	if line.fEdge == nil {
-		err = syscall_nonblock_wrapper(int(line.fd), true)
-		if err != nil {
-			log.Println("WaitForEdge() SetNonblock(): ", err)
-			return false
-		}
-		line.fEdge = os.NewFile(uintptr(line.fd), fmt.Sprintf("gpio-%d", line.number))
+	// The deadline must be set before, otherwise it could overwrite the one set
+	// when ctx is done.
+	stop := interruptOnDone(ctx, line.fEdge)
+	defer stop()
+	var event gpio_v2_line_event
+	// If the read times out, or is interrupted via Halt() or ctx, it will
+	// return "i/o timeout"
+	if err := binary.Read(line.fEdge, binary.LittleEndian, &event); err != nil {
+		// This wait consumed the Halt(), if any.
+		line.halted.Store(false)
+		return false
 	}
+	return true
+}
 
+// WaitForEvent waits for an edge on the line, like WaitForEdge(), and returns
+// which edge was detected. This is useful for a line configured for
+// gpio.BothEdges.
+//
+// t is the time the event was received. The kernel timestamp, from
+// CLOCK_MONOTONIC, is available via LineSet.WaitForEvent().
+//
+// timeout for the edge change to occur. If 0, waits forever. If a timeout or
+// halt occurred, an error is returned.
+func (line *GPIOLine) WaitForEvent(timeout time.Duration) (edge gpio.Edge, t time.Time, err error) {
+	if err := line.prepareWait(timeout); err != nil {
+		return gpio.NoEdge, time.Time{}, err
+	}
+	var event gpio_v2_line_event
+	if err := binary.Read(line.fEdge, binary.LittleEndian, &event); err != nil {
+		// This wait consumed the Halt(), if any.
+		line.halted.Store(false)
+		return gpio.NoEdge, time.Time{}, fmt.Errorf("GPIOLine.WaitForEvent(): %w", err)
+	}
+	return newLineEvent(&event).Edge, time.Now(), nil
+}
+
+// prepareWait readies the line for reading edge events, with a read deadline
+// of timeout, 0 meaning forever.
+func (line *GPIOLine) prepareWait(timeout time.Duration) error {
+	if line.edge == gpio.NoEdge || line.direction == LineDirNotSet {
+		return errors.New("WaitForEdge(): line hasn't been configured for edge detection")
+	}
+	if err := line.openEdge(); err != nil {
+		return fmt.Errorf("WaitForEdge() SetNonblock(): %w", err)
+	}
+	var err error
 	if timeout == 0 {
 		err = line.fEdge.SetReadDeadline(time.Time{})
 	} else {
 		err = line.fEdge.SetReadDeadline(time.Now().Add(timeout))
 	}
 	if err != nil {
-		log.Println("GPIOLine.WaitForEdge() setReadDeadline() returned:", err)
-		return false
+		return fmt.Errorf("GPIOLine.WaitForEdge() setReadDeadline() returned: %w", err)
 	}
-	var event gpio_v2_line_event
-	// If the read times out, or is interrupted via Halt(), it will
-	// return "i/o timeout"
-	err = binary.Read(line.fEdge, binary.LittleEndian, &event)
+	// Checked after setting the deadline, so a Halt() called from now on
+	// interrupts the read.
+	if line.halted.Swap(false) {
+		return errHalted
+	}
+	return nil
+}
+
+// openEdge opens the file used to read the edge events, if not already done.
+func (line *GPIOLine) openEdge() error {
+	if line.fEdge == nil {
+		if err := syscall_nonblock_wrapper(int(line.fd), true); err != nil {
+			return err
+		}
+		line.fEdge = os.NewFile(uintptr(line.fd), fmt.Sprintf("gpio-%d", line.number))
+	}
+	return nil
+}
+
+// MeasureFrequency measures the frequency of the signal on the line by
+// counting the edges detected during window.
+//
+// The line is configured for both edges detection if needed. The frequency
+// is calculated from the kernel timestamps of the first and last edges, and
+// from their sequence numbers so the edges dropped by the kernel are still
+// accounted for. It returns 0 if less than two edges were detected.
+func (line *GPIOLine) MeasureFrequency(window time.Duration) (physic.Frequency, error) {
+	if window <= 0 {
+		return 0, errors.New("GPIOLine.MeasureFrequency(): window must be positive")
+	}
+	if line.direction != LineInput || line.edge != gpio.BothEdges {
+		if err := line.In(line.pull, gpio.BothEdges); err != nil {
+			return 0, fmt.Errorf("GPIOLine.MeasureFrequency(): %w", err)
+		}
+	}
+	if err := line.openEdge(); err != nil {
+		return 0, fmt.Errorf("GPIOLine.MeasureFrequency(): %w", err)
+	}
+	if err := line.fEdge.SetReadDeadline(time.Now().Add(window)); err != nil {
+		return 0, fmt.Errorf("GPIOLine.MeasureFrequency(): %w", err)
+	}
+	if line.halted.Swap(false) {
+		return 0, errors.New("GPIOLine.MeasureFrequency(): halted")
+	}
+	var first, last gpio_v2_line_event
+	n := 0
+	for {
+		var event gpio_v2_line_event
+		if err := binary.Read(line.fEdge, binary.LittleEndian, &event); err != nil {
+			// This measurement consumed the Halt(), if any.
+			line.halted.Store(false)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			return 0, fmt.Errorf("GPIOLine.MeasureFrequency(): %w", err)
+		}
+		if n == 0 {
+			first = event
+		}
+		last = event
+		n++
+	}
+	return edgesFrequency(&first, &last, n), nil
+}
+
+// edgesFrequency returns the frequency of a signal given its first and last
+// edge events, when n events were read.
+func edgesFrequency(first, last *gpio_v2_line_event, n int) physic.Frequency {
+	if n < 2 || last.Timestamp_ns <= first.Timestamp_ns {
+		return 0
+	}
+	// There are two edges per period.
+	edges := float64(last.LineSeqno - first.LineSeqno)
+	dt := time.Duration(last.Timestamp_ns - first.Timestamp_ns)
+	return physic.Frequency(edges / 2 / dt.Seconds() * float64(physic.Hertz))
+}
 
-	return err == nil
+// interruptOnDone interrupts any pending read on f once ctx is done, the same
+// way Halt() does. The returned function must be called once the read is
+// completed.
+func interruptOnDone(ctx context.Context, f *os.File) func() bool {
+	return context.AfterFunc(ctx, func() {
+		_ = f.SetReadDeadline(time.UnixMilli(0))
+	})
 }
 
 // Return the file descriptor associated with this line. If it
@@ -260,23 +563,46 @@ func (line *GPIOLine) getLine() (int32, error) {
 	if line.fd != 0 {
 		return line.fd, nil
 	}
+	return line.request(&gpio_v2_line_config{})
+}
+
+// request requests the line from the kernel with the configuration lc.
+func (line *GPIOLine) request(lc *gpio_v2_line_config) (int32, error) {
+	if line.chip_fd == 0 {
+		return 0, errChipClosed
+	}
 	var req gpio_v2_line_request
 	req.offsets[0] = uint32(line.number)
 	req.num_lines = 1
 	for ix, charval := range []byte(consumer) {
 		req.consumer[ix] = charval
 	}
+	req.config = *lc
 
 	err := ioctl_gpio_v2_line_request(uintptr(line.chip_fd), &req)
 	if err == nil {
 		line.fd = req.fd
 		line.consumer = string(consumer)
 	} else {
-		err = fmt.Errorf("line_request ioctl: %w", err)
+		err = requestError("line_request ioctl", err)
 	}
 	return line.fd, err
 }
 
+// requestError wraps a failed line request, adding a hint for the common
+// failure reasons. The underlying IoctlError is preserved.
+func requestError(prefix string, err error) error {
+	switch {
+	case errors.Is(err, syscall.EBUSY):
+		return fmt.Errorf("%s: line already in use by another consumer: %w", prefix, err)
+	case errors.Is(err, os.ErrPermission):
+		return fmt.Errorf("%s: need more access, try as root or setup udev rules: %w", prefix, err)
+	case errors.Is(err, syscall.EINVAL):
+		return fmt.Errorf("%s: configuration rejected by the kernel: %w", prefix, err)
+	}
+	return fmt.Errorf("%s: %w", prefix, err)
+}
+
 func (line *GPIOLine) setOut() error {
 	line.direction = LineOutput
 	line.edge = gpio.NoEdge
@@ -308,7 +634,8 @@ func (line *GPIOLine) Func() pin.Func {
 		}
 		return gpio.IN_LOW
 	} else if line.direction == LineOutput {
-		if line.Read() {
+		// Use the cached value, Read() would reconfigure the line as an input.
+		if line.level {
 			return gpio.OUT_HIGH
 		}
 		return gpio.OUT_LOW
@@ -335,6 +662,76 @@ func (line *GPIOLine) SetFunc(f pin.Func) error {
 	}
 }
 
+// InvertedLine returns a wrapper of the line with active-low semantics: Out(High)
+// drives the line low, Read() returns High when the line is low and the
+// rising and falling edges are swapped.
+//
+// The inversion is done in software, so it works even with controllers whose
+// driver doesn't honor the kernel active-low flag.
+func InvertedLine(l *GPIOLine) gpio.PinIO {
+	return &invertedLine{GPIOLine: l}
+}
+
+// invertedLine is the gpio.PinIO returned by InvertedLine().
+type invertedLine struct {
+	*GPIOLine
+}
+
+// In implements gpio.PinIn.
+func (i *invertedLine) In(pull gpio.Pull, edge gpio.Edge) error {
+	switch edge {
+	case gpio.RisingEdge:
+		edge = gpio.FallingEdge
+	case gpio.FallingEdge:
+		edge = gpio.RisingEdge
+	}
+	return i.GPIOLine.In(pull, edge)
+}
+
+// Read implements gpio.PinIn.
+func (i *invertedLine) Read() gpio.Level {
+	return !i.GPIOLine.Read()
+}
+
+// Out implements gpio.PinOut.
+func (i *invertedLine) Out(l gpio.Level) error {
+	return i.GPIOLine.Out(!l)
+}
+
+// Function implements pin.Pin.
+func (i *invertedLine) Function() string {
+	return string(i.Func())
+}
+
+// Func implements pin.PinFunc.
+func (i *invertedLine) Func() pin.Func {
+	switch i.GPIOLine.Func() {
+	case gpio.IN_HIGH:
+		return gpio.IN_LOW
+	case gpio.IN_LOW:
+		return gpio.IN_HIGH
+	case gpio.OUT_HIGH:
+		return gpio.OUT_LOW
+	case gpio.OUT_LOW:
+		return gpio.OUT_HIGH
+	}
+	return pin.FuncNone
+}
+
+// SetFunc implements pin.PinFunc.
+func (i *invertedLine) SetFunc(f pin.Func) error {
+	switch f {
+	case gpio.IN:
+		return i.In(gpio.PullNoChange, gpio.NoEdge)
+	case gpio.OUT_HIGH:
+		return i.Out(gpio.High)
+	case gpio.OUT, gpio.OUT_LOW:
+		return i.Out(gpio.Low)
+	default:
+		return errors.New("unsupported function")
+	}
+}
+
 // A representation of a Linux GPIO Chip. A computer may have
 // more than one GPIOChip.
 type GPIOChip struct {
@@ -348,6 +745,12 @@ type GPIOChip struct {
 	lineCount int
 	// The set of Lines associated with this device.
 	lines []*GPIOLine
+	// Index of lines by name, built on the first ByName() call, once the
+	// lines were named by registerLines().
+	byName     map[string]*GPIOLine
+	byNameOnce sync.Once
+	// Protects lineSets.
+	mu sync.Mutex
 	// The LineSets opened on this device.
 	lineSets []*LineSet
 	// The file descriptor to the Path device.
@@ -377,25 +780,31 @@ func (chip *GPIOChip) Lines() []*GPIOLine {
 }
 
 func (chip *GPIOChip) LineSets() []*LineSet {
-	return chip.lineSets
+	chip.mu.Lock()
+	defer chip.mu.Unlock()
+	return slices.Clone(chip.lineSets)
 }
 
 // Construct a new GPIOChip by opening the /dev/gpiochip*
 // path specified and using Kernel ioctl() calls to
 // read information about the chip and it's associated lines.
 func newGPIOChip(path string) (*GPIOChip, error) {
-	chip := GPIOChip{path: path}
 	f, err := os.OpenFile(path, os.O_RDONLY, 0400)
 	if err != nil {
 		err = fmt.Errorf("opening gpio chip %s failed. error: %w", path, err)
 		log.Println(err)
 		return nil, err
 	}
-	chip.file = f
+	return newGPIOChipFromFile(path, f)
+}
+
+// newGPIOChipFromFile reads the information about the chip opened as f and
+// its lines.
+func newGPIOChipFromFile(path string, f *os.File) (*GPIOChip, error) {
+	chip := GPIOChip{path: path, file: f}
 	chip.fd = chip.file.Fd()
-	os.NewFile(uintptr(chip.fd), "GPIO Chip - "+path)
 	var info gpiochip_info
-	err = ioctl_gpiochip_info(chip.fd, &info)
+	err := ioctl_gpiochip_info(chip.fd, &info)
 	if err != nil {
 		log.Printf("newGPIOChip: %s\n", err)
 		return nil, fmt.Errorf("newgpiochip %s: %w", path, err)
@@ -413,39 +822,82 @@ func newGPIOChip(path string) (*GPIOChip, error) {
 		err := ioctl_gpio_v2_line_info(chip.fd, &line_info)
 		if err != nil {
 			log.Println("newGPIOChip get line info", err)
+			if line == 0 && isNoUAPIv2(err) {
+				return nil, fmt.Errorf("reading line info: %w: %w", ErrNoUAPIv2, err)
+			}
 			return nil, fmt.Errorf("reading line info: %w", err)
 		}
 		line := newGPIOLine(uint32(line), string(line_info.name[:]), string(line_info.consumer[:]), chip.fd)
+		line.kernelFlags = line_info.flags
 		chip.lines = append(chip.lines, line)
 	}
 	return &chip, nil
 }
 
+// ErrNoUAPIv2 is returned when the kernel doesn't implement the GPIO v2
+// character device uAPI, which was added in Linux 5.10. The older v1 uAPI is
+// not supported.
+var ErrNoUAPIv2 = errors.New("gpioioctl: the kernel lacks the GPIO v2 uAPI, Linux 5.10 or later is required")
+
+// isNoUAPIv2 returns true if err is how a kernel predating the v2 uAPI
+// rejects its ioctls: the chip info ioctl works, but the v2 requests are
+// unknown.
+func isNoUAPIv2(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY)
+}
+
+// errChipClosed is returned when using a line of a closed chip.
+var errChipClosed = errors.New("the GPIO chip is closed")
+
 // Close closes the file descriptor associated with the chipset,
 // along with any configured Lines and LineSets.
-func (chip *GPIOChip) Close() {
-	_ = chip.file.Close()
-
+//
+// The lines can't be requested anymore afterward. It is safe to call Close()
+// multiple times.
+//
+// The errors returned while closing the lines, line sets and the chip itself
+// are aggregated into the returned error.
+func (chip *GPIOChip) Close() error {
+	var errs []error
 	for _, line := range chip.lines {
-		if line.fd != 0 {
-			line.Close()
+		line.mu.Lock()
+		if err := line.closeLocked(); err != nil {
+			errs = append(errs, fmt.Errorf("closing line %s: %w", line.Name(), err))
 		}
+		// Detach the line so it's not requested on a stale chip descriptor.
+		line.chip_fd = 0
+		line.mu.Unlock()
 	}
-	for _, lineset := range chip.lineSets {
-		_ = lineset.Close()
+	for _, lineset := range chip.LineSets() {
+		if err := lineset.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing lineset: %w", err))
+		}
+	}
+	// chip.file owns chip.fd, so closing the file releases the descriptor.
+	// Don't close chip.fd separately, it would be a double close.
+	if chip.file != nil {
+		if err := chip.file.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing chip %s: %w", chip.Name(), err))
+		}
+		chip.file = nil
 	}
-	_ = syscall_close_wrapper(int(chip.fd))
+	chip.fd = 0
+	return errors.Join(errs...)
 }
 
 // ByName returns a GPIOLine for a specific name. If not
 // found, returns nil.
 func (chip *GPIOChip) ByName(name string) *GPIOLine {
-	for _, line := range chip.lines {
-		if line.name == name {
-			return line
+	chip.byNameOnce.Do(func() {
+		chip.byName = make(map[string]*GPIOLine, len(chip.lines))
+		for _, line := range chip.lines {
+			// Keep the first line when a name is duplicated.
+			if _, ok := chip.byName[line.name]; !ok {
+				chip.byName[line.name] = line
+			}
 		}
-	}
-	return nil
+	})
+	return chip.byName[name]
 }
 
 // ByNumber returns a line by it's specific GPIO Chip line
@@ -459,8 +911,33 @@ func (chip *GPIOChip) ByNumber(number int) *GPIOLine {
 	return chip.lines[number]
 }
 
+// validateFlags returns a descriptive error if the combination of GPIO
+// configuration values is invalid, so it is caught before issuing the ioctl.
+func validateFlags(dir LineDir, edge gpio.Edge, pull gpio.Pull) error {
+	if dir > LineOutput {
+		return fmt.Errorf("invalid direction %d", dir)
+	}
+	if edge > gpio.BothEdges {
+		return fmt.Errorf("invalid edge %d", edge)
+	}
+	if pull > gpio.PullUp {
+		return fmt.Errorf("invalid pull %d", pull)
+	}
+	if edge != gpio.NoEdge && dir != LineInput {
+		return fmt.Errorf("edge detection %s requires LineInput", edge)
+	}
+	if pull != gpio.PullNoChange && dir == LineDirNotSet {
+		return fmt.Errorf("pull %s requires LineInput or LineOutput", pull)
+	}
+	return nil
+}
+
 // getFlags accepts a set of GPIO configuration values and returns an
-// appropriate uint64 ioctl gpio flag.
+// appropriate uint64 ioctl gpio flag. The values must have been checked with
+// validateFlags.
+//
+// gpio.PullNoChange doesn't emit any bias flag, so the line keeps the bias
+// already configured on the pad. gpio.Float explicitly disables the bias.
 func getFlags(dir LineDir, edge gpio.Edge, pull gpio.Pull) uint64 {
 	var flags uint64
 	if dir == LineInput {
@@ -468,10 +945,13 @@ func getFlags(dir LineDir, edge gpio.Edge, pull gpio.Pull) uint64 {
 	} else if dir == LineOutput {
 		flags |= _GPIO_V2_LINE_FLAG_OUTPUT
 	}
-	if pull == gpio.PullUp {
+	switch pull {
+	case gpio.PullUp:
 		flags |= _GPIO_V2_LINE_FLAG_BIAS_PULL_UP
-	} else if pull == gpio.PullDown {
+	case gpio.PullDown:
 		flags |= _GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN
+	case gpio.Float:
+		flags |= _GPIO_V2_LINE_FLAG_BIAS_DISABLED
 	}
 	if edge == gpio.RisingEdge {
 		flags |= _GPIO_V2_LINE_FLAG_EDGE_RISING
@@ -483,31 +963,304 @@ func getFlags(dir LineDir, edge gpio.Edge, pull gpio.Pull) uint64 {
 	return flags
 }
 
+// parseFlags is the inverse of getFlags. It decodes the flags reported by the
+// kernel for a line.
+func parseFlags(flags uint64) (dir LineDir, edge gpio.Edge, pull gpio.Pull) {
+	if flags&_GPIO_V2_LINE_FLAG_INPUT != 0 {
+		dir = LineInput
+	} else if flags&_GPIO_V2_LINE_FLAG_OUTPUT != 0 {
+		dir = LineOutput
+	}
+	switch {
+	case flags&_GPIO_V2_LINE_FLAG_BIAS_PULL_UP != 0:
+		pull = gpio.PullUp
+	case flags&_GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN != 0:
+		pull = gpio.PullDown
+	case flags&_GPIO_V2_LINE_FLAG_BIAS_DISABLED != 0:
+		pull = gpio.Float
+	default:
+		pull = gpio.PullNoChange
+	}
+	rising := flags&_GPIO_V2_LINE_FLAG_EDGE_RISING != 0
+	falling := flags&_GPIO_V2_LINE_FLAG_EDGE_FALLING != 0
+	switch {
+	case rising && falling:
+		edge = gpio.BothEdges
+	case rising:
+		edge = gpio.RisingEdge
+	case falling:
+		edge = gpio.FallingEdge
+	default:
+		edge = gpio.NoEdge
+	}
+	return dir, edge, pull
+}
+
+// LineNotFoundError is returned when requesting a line by a name that doesn't
+// exist on the chip.
+type LineNotFoundError struct {
+	Line string
+	Chip string
+	// OtherChip is the label of another chip in Chips that has a line with
+	// this name, if any.
+	OtherChip string
+}
+
+func (e *LineNotFoundError) Error() string {
+	if e.OtherChip != "" {
+		return fmt.Sprintf("line %s not found on %s; did you mean chip %s?", e.Line, e.Chip, e.OtherChip)
+	}
+	return fmt.Sprintf("line %s not found on %s", e.Line, e.Chip)
+}
+
+// lineNotFound returns a LineNotFoundError for name, searching Chips for
+// another chip exposing this line.
+func (chip *GPIOChip) lineNotFound(name string) error {
+	err := &LineNotFoundError{Line: name, Chip: chip.Label()}
+	for _, c := range Chips {
+		if c != chip && c.ByName(name) != nil {
+			err.OtherChip = c.Label()
+			break
+		}
+	}
+	return err
+}
+
+// LineConfig is the configuration of a single line claimed with
+// GPIOChip.ClaimLine().
+type LineConfig struct {
+	Direction LineDir
+	Edge      gpio.Edge
+	Pull      gpio.Pull
+	// Level is the initial level of an output line, applied atomically with
+	// the line request.
+	Level gpio.Level
+}
+
+// ClaimLine requests the line name with the configuration cfg immediately,
+// instead of on its first use like the lines returned by ByName().
+//
+// It permits to validate at startup that all the required lines are
+// available, as a line in use by another consumer or not accessible fails
+// here rather than on the first I/O. The line stays requested until Close().
+func (chip *GPIOChip) ClaimLine(name string, cfg LineConfig) (*GPIOLine, error) {
+	if err := validateFlags(cfg.Direction, cfg.Edge, cfg.Pull); err != nil {
+		return nil, fmt.Errorf("GPIOChip.ClaimLine(%s): %w", name, err)
+	}
+	line := chip.ByName(name)
+	if line == nil {
+		return nil, chip.lineNotFound(name)
+	}
+	lc := gpio_v2_line_config{flags: getFlags(cfg.Direction, cfg.Edge, cfg.Pull)}
+	if cfg.Direction == LineOutput {
+		attr := gpio_v2_line_attribute{id: _GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES}
+		if cfg.Level {
+			attr.value = 1
+		}
+		lc.attrs[0] = gpio_v2_line_config_attribute{attr: attr, mask: 1}
+		lc.num_attrs = 1
+	}
+	line.mu.Lock()
+	defer line.mu.Unlock()
+	var err error
+	if line.fd != 0 {
+		// Already requested by this process, reconfigure it.
+		err = ioctl_gpio_v2_line_config(uintptr(line.fd), &lc)
+	} else {
+		_, err = line.request(&lc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GPIOChip.ClaimLine(%s): %w", name, err)
+	}
+	line.direction = cfg.Direction
+	line.edge = cfg.Edge
+	line.pull = cfg.Pull
+	if cfg.Direction == LineOutput {
+		line.level = cfg.Level
+	}
+	return line, nil
+}
+
 // Create a LineSet using the configuration specified by config.
 func (chip *GPIOChip) LineSetFromConfig(config *LineSetConfig) (*LineSet, error) {
 	lines := make([]uint32, len(config.Lines))
 	for ix, name := range config.Lines {
 		gpioLine := chip.ByName(name)
 		if gpioLine == nil {
-			return nil, fmt.Errorf("line %s not found in chip %s", name, chip.Name())
+			return nil, chip.lineNotFound(name)
 		}
 		lines[ix] = uint32(gpioLine.Number())
 	}
-	req := config.getLineSetRequestStruct(lines)
-
-	err := ioctl_gpio_v2_line_request(chip.fd, req)
+	req, err := config.getLineSetRequestStruct(lines)
 	if err != nil {
 		return nil, fmt.Errorf("LineSetFromConfig: %w", err)
 	}
-	ls := LineSet{fd: req.fd}
+
+	err = ioctl_gpio_v2_line_request(chip.fd, req)
+	if err != nil {
+		return nil, requestError("LineSetFromConfig", err)
+	}
+	ls := &LineSet{fd: req.fd, chip: chip}
 
 	for offset, lineName := range config.Lines {
 		lsl := chip.newLineSetLine(int(chip.ByName(lineName).Number()), offset, config)
-		lsl.parent = &ls
+		lsl.parent = ls
 		ls.lines = append(ls.lines, lsl)
 	}
+	chip.mu.Lock()
+	chip.lineSets = append(chip.lineSets, ls)
+	chip.mu.Unlock()
 
-	return &ls, nil
+	return ls, nil
+}
+
+// ReadAll returns a snapshot of the level of all the named lines of the chip.
+//
+// The lines not already requested by this process are transiently requested
+// as inputs, at most 64 at a time, read in one operation, then released. Note
+// that this reconfigures unclaimed output lines as inputs. It fails with
+// syscall.EBUSY if another process holds one of the lines.
+func (chip *GPIOChip) ReadAll() (map[string]gpio.Level, error) {
+	out := make(map[string]gpio.Level, len(chip.lines))
+	var pending []*GPIOLine
+	for _, line := range chip.lines {
+		if line.Name() == "" {
+			continue
+		}
+		line.mu.Lock()
+		fd := line.fd
+		line.mu.Unlock()
+		if fd == 0 {
+			pending = append(pending, line)
+			continue
+		}
+		// Already requested by this process; read it directly.
+		data := gpio_v2_line_values{mask: 0x01}
+		if err := ioctl_get_gpio_v2_line_values(uintptr(fd), &data); err != nil {
+			return nil, fmt.Errorf("ReadAll: %w", err)
+		}
+		out[line.Name()] = data.bits&0x01 == 0x01
+	}
+	for len(pending) != 0 {
+		n := min(len(pending), _GPIO_V2_LINES_MAX)
+		var req gpio_v2_line_request
+		for ix, char := range []byte(consumer) {
+			req.consumer[ix] = char
+		}
+		for ix, line := range pending[:n] {
+			req.setLineNumber(ix, line.number)
+		}
+		req.num_lines = uint32(n)
+		req.config.flags = getFlags(LineInput, gpio.NoEdge, gpio.PullNoChange)
+		if err := ioctl_gpio_v2_line_request(chip.fd, &req); err != nil {
+			return nil, requestError("ReadAll", err)
+		}
+		data := gpio_v2_line_values{mask: ^uint64(0) >> (64 - n)}
+		err := ioctl_get_gpio_v2_line_values(uintptr(req.fd), &data)
+		_ = syscall_close_wrapper(int(req.fd))
+		if err != nil {
+			return nil, fmt.Errorf("ReadAll: %w", err)
+		}
+		for ix, line := range pending[:n] {
+			out[line.Name()] = data.bits&(uint64(1)<<ix) != 0
+		}
+		pending = pending[n:]
+	}
+	return out, nil
+}
+
+// ExportConfig returns LineSetConfigs reflecting the current direction, edge
+// detection and bias of the lines of the chip, as reported by the kernel.
+//
+// It permits to snapshot a working configuration, e.g. as JSON, and to apply
+// it later with LineSetFromConfig(). Since a line request is limited to 64
+// lines, a LineSetConfig is returned for each group of 64 consecutive named
+// lines. In each, the most common configuration is used as the default and
+// the others are added as overrides. The levels of the output lines are not
+// captured.
+func (chip *GPIOChip) ExportConfig() ([]*LineSetConfig, error) {
+	var lines []exportedLine
+	for _, line := range chip.lines {
+		if line.Name() == "" {
+			continue
+		}
+		info := gpio_v2_line_info{offset: line.number}
+		if err := ioctl_gpio_v2_line_info(chip.fd, &info); err != nil {
+			return nil, fmt.Errorf("ExportConfig: %w", err)
+		}
+		l := exportedLine{name: line.Name()}
+		l.dir, l.edge, l.pull = parseFlags(info.flags)
+		lines = append(lines, l)
+	}
+	var out []*LineSetConfig
+	for len(lines) != 0 {
+		n := min(len(lines), _GPIO_V2_LINES_MAX)
+		cfg, err := exportConfig(lines[:n])
+		if err != nil {
+			return nil, fmt.Errorf("ExportConfig: %w", err)
+		}
+		out = append(out, cfg)
+		lines = lines[n:]
+	}
+	return out, nil
+}
+
+// exportedLine is the state of a line as read by ExportConfig().
+type exportedLine struct {
+	name string
+	dir  LineDir
+	edge gpio.Edge
+	pull gpio.Pull
+}
+
+// exportConfig returns the LineSetConfig reproducing the state of lines.
+func exportConfig(lines []exportedLine) (*LineSetConfig, error) {
+	type state struct {
+		dir  LineDir
+		edge gpio.Edge
+		pull gpio.Pull
+	}
+	var order []state
+	groups := make(map[state][]string)
+	cfg := &LineSetConfig{}
+	for _, l := range lines {
+		s := state{l.dir, l.edge, l.pull}
+		if _, ok := groups[s]; !ok {
+			order = append(order, s)
+		}
+		groups[s] = append(groups[s], l.name)
+		cfg.Lines = append(cfg.Lines, l.name)
+	}
+	def := order[0]
+	for _, s := range order[1:] {
+		if len(groups[s]) > len(groups[def]) {
+			def = s
+		}
+	}
+	cfg.DefaultDirection, cfg.DefaultEdge, cfg.DefaultPull = def.dir, def.edge, def.pull
+	for _, s := range order {
+		if s != def {
+			cfg.Overrides = append(cfg.Overrides, &LineConfigOverride{Lines: groups[s], Direction: s.dir, Edge: s.edge, Pull: s.pull})
+		}
+	}
+	if n := cfg.numAttrs(); n > _GPIO_V2_LINE_NUM_ATTRS_MAX {
+		return nil, fmt.Errorf("%d distinct line configurations; a maximum of %d overrides can be configured", n+1, _GPIO_V2_LINE_NUM_ATTRS_MAX)
+	}
+	return cfg, nil
+}
+
+// RequestLines requests all the lines in config with a single kernel request
+// and returns both the individual lines and the LineSet that owns them.
+//
+// Each returned LineSetLine implements gpio.PinIO and shares the LineSet's
+// file descriptor, so the lines are configured atomically but can still be
+// used as individual pins. Closing the LineSet releases all of them.
+func (chip *GPIOChip) RequestLines(config *LineSetConfig) ([]*LineSetLine, *LineSet, error) {
+	ls, err := chip.LineSetFromConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ls.Lines(), ls, nil
 }
 
 // Create a representation of a specific line in the set.
@@ -527,6 +1280,7 @@ func (chip *GPIOChip) newLineSetLine(line_number, offset int, config *LineSetCon
 				lsl.direction = override.Direction
 				lsl.edge = override.Edge
 				lsl.pull = override.Pull
+				lsl.debounce = override.Debounce
 
 			}
 		}
@@ -548,7 +1302,7 @@ func (chip *GPIOChip) MarshalJSON() ([]byte, error) {
 		Label:     chip.Label(),
 		LineCount: chip.LineCount(),
 		Lines:     chip.lines,
-		LineSets:  chip.lineSets})
+		LineSets:  chip.LineSets()})
 }
 
 // String returns the chip information, and line information in JSON format.
@@ -557,6 +1311,118 @@ func (chip *GPIOChip) String() string {
 	return string(json)
 }
 
+// Dump returns all the chips found on the running device, along with their
+// lines, in JSON format. It is meant to be pasted in bug reports.
+func Dump() string {
+	json, _ := json.MarshalIndent(Chips, "", "    ")
+	return string(json)
+}
+
+// Shutdown releases every LineSet and GPIOLine of every chip in Chips,
+// unregisters the lines from gpioreg and closes the chips. Chips is empty
+// afterward.
+//
+// It is meant to be called on program exit so the lines are handed back to
+// the kernel in a known state. The errors encountered are aggregated.
+func Shutdown() error {
+	var errs []error
+	for _, chip := range Chips {
+		errs = append(errs, chip.unregisterLines()...)
+		if err := chip.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	Chips = nil
+	return errors.Join(errs...)
+}
+
+// Rescan updates Chips to match the /dev/gpiochip* device nodes currently
+// present, e.g. to handle USB GPIO expanders plugged in after host.Init().
+//
+// The new chips are appended to Chips and their lines registered with
+// gpioreg. The chips whose device node vanished are closed, their lines
+// unregistered and they are removed from Chips.
+func Rescan() error {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	items, err := filepath.Glob("/dev/gpiochip*")
+	if err != nil {
+		return fmt.Errorf("gpioioctl: %w", err)
+	}
+	var errs []error
+	kept := Chips[:0]
+	mID := make(map[string]struct{})
+	for _, chip := range Chips {
+		if chip.vanished() {
+			errs = append(errs, chip.unregisterLines()...)
+			if err := chip.Close(); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		kept = append(kept, chip)
+		mID[chip.deviceID()] = struct{}{}
+	}
+	clear(Chips[len(kept):])
+	Chips = kept
+
+	var chips []*GPIOChip
+	for _, item := range items {
+		chip, err := newGPIOChip(item)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		id := chip.deviceID()
+		if _, found := mID[id]; found {
+			_ = chip.Close()
+			continue
+		}
+		mID[id] = struct{}{}
+		chips = append(chips, chip)
+	}
+	sortChips(chips)
+	registeredPins := make(map[string]struct{})
+	for _, pin := range gpioreg.All() {
+		registeredPins[pin.Name()] = struct{}{}
+	}
+	for _, chip := range chips {
+		Chips = append(Chips, chip)
+		chip.registerLines(registeredPins)
+	}
+	return errors.Join(errs...)
+}
+
+// vanished returns true if the device node of the chip was removed, or
+// replaced by another device.
+func (chip *GPIOChip) vanished() bool {
+	fi, err := os.Stat(chip.path)
+	if err != nil {
+		return true
+	}
+	if chip.file == nil {
+		return false
+	}
+	cur, err := chip.file.Stat()
+	return err != nil || !os.SameFile(fi, cur)
+}
+
+// unregisterLines unregisters the lines of the chip from gpioreg.
+func (chip *GPIOChip) unregisterLines() []error {
+	var errs []error
+	for _, line := range chip.lines {
+		// Skip the lines whose name was taken by another chip.
+		if p := gpioreg.ByName(line.Name()); p != gpio.PinIO(line) {
+			continue
+		}
+		if err := gpioreg.Unregister(line.Name()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
 // LineSet requests a set of io pins and configures them according to the
 // parameters. Using a LineSet, you can perform IO operations on multiple
 // lines in a single operation. For more control, see LineSetFromConfig.
@@ -565,13 +1431,61 @@ func (chip *GPIOChip) LineSet(defaultDirection LineDir, defaultEdge gpio.Edge, d
 	for _, lineName := range lines {
 		p := chip.ByName(lineName)
 		if p == nil {
-			return nil, fmt.Errorf("line %s not found", lineName)
+			return nil, chip.lineNotFound(lineName)
 		}
 		cfg.Lines = append(cfg.Lines, p.Name())
 	}
 	return chip.LineSetFromConfig(cfg)
 }
 
+// Button requests the line name as a debounced input with a pull up, and
+// reports its state changes on the returned channel: true when the button is
+// pressed, that is when the line goes low, and false when it is released.
+//
+// The kernel handles the debouncing, so each state change is reported once.
+// Call the returned function to stop watching the line and release it; the
+// channel is closed afterward.
+func (chip *GPIOChip) Button(name string, debounce time.Duration) (<-chan bool, func(), error) {
+	cfg := &LineSetConfig{Lines: []string{name}, DefaultDirection: LineInput, DefaultEdge: gpio.BothEdges, DefaultPull: gpio.PullUp}
+	if err := cfg.AddDebouncedOverrides(LineInput, gpio.BothEdges, gpio.PullUp, debounce, name); err != nil {
+		return nil, nil, fmt.Errorf("Button: %w", err)
+	}
+	ls, err := chip.LineSetFromConfig(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Button: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan bool)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(c)
+		for {
+			_, edge, err := ls.WaitForEdgeContext(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Println("GPIOChip.Button():", err)
+				}
+				return
+			}
+			select {
+			case c <- edge == gpio.FallingEdge:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			cancel()
+			<-done
+			_ = ls.Close()
+		})
+	}
+	return c, stop, nil
+}
+
 // driverGPIO implements periph.Driver.
 type driverGPIO struct {
 	_ string
@@ -608,32 +1522,35 @@ func (d *driverGPIO) Init() (bool, error) {
 	// First, get all of the chips on the system.
 	var chips []*GPIOChip
 	var chip *GPIOChip
+	var permErr, v2Err error
+	permDenied := 0
+	noV2 := 0
 	for _, item := range items {
 		chip, err = newGPIOChip(item)
 		if err == nil {
 			chips = append(chips, chip)
 		} else {
 			log.Println("gpioioctl.driverGPIO.Init() Error", err)
-		}
-	}
-	// Now, sort the chips so that those labeled with pinctrl- ( a Pi kernel standard)
-	// come first. Otherwise, sort them by label. This _should_ protect us from any
-	// random changes in chip naming/ordering.
-	sort.Slice(chips, func(i, j int) bool {
-		I := chips[i]
-		J := chips[j]
-		if strings.HasPrefix(I.Label(), "pinctrl-") {
-			if strings.HasPrefix(J.Label(), "pinctrl-") {
-				return I.Label() < J.Label()
+			if errors.Is(err, os.ErrPermission) {
+				permDenied++
+				permErr = err
+			} else if errors.Is(err, ErrNoUAPIv2) {
+				noV2++
+				v2Err = err
 			}
-			return true
-		} else if strings.HasPrefix(J.Label(), "pinctrl-") {
-			return false
 		}
-		return I.Label() < J.Label()
-	})
+	}
+	if permDenied == len(items) {
+		// All the chips exist but none could be opened; hint the user instead of
+		// silently registering nothing.
+		return true, fmt.Errorf("need more access, try as root or setup udev rules: %w", permErr)
+	}
+	if noV2 == len(items) {
+		return true, v2Err
+	}
+	sortChips(chips)
 
-	mName := make(map[string]struct{})
+	mID := make(map[string]struct{})
 	// Get a list of already registered GPIO Line names.
 	registeredPins := make(map[string]struct{})
 	for _, pin := range gpioreg.All() {
@@ -642,37 +1559,102 @@ func (d *driverGPIO) Init() (bool, error) {
 
 	// Now, iterate over the chips we found and add their lines to conn/gpio/gpioreg
 	for _, chip := range chips {
-		// On a pi, gpiochip0 is also symlinked to gpiochip4, checking the map
-		// ensures we don't duplicate the chip.
-		if _, found := mName[chip.Name()]; !found {
+		// On a pi, gpiochip0 is also symlinked to gpiochip4, checking the
+		// identity of the device node ensures we don't duplicate the chip, while
+		// distinct controllers sharing a name are kept.
+		id := chip.deviceID()
+		if _, found := mID[id]; found {
+			_ = chip.Close()
+		} else {
 			Chips = append(Chips, chip)
-			mName[chip.Name()] = struct{}{}
-			// Now, iterate over the lines on this chip.
-			for _, line := range chip.lines {
-				// If the line has some sort of reasonable name...
-				if len(line.name) > 0 && line.name != "_" && line.name != "-" {
-					// See if the name is already registered. On the Pi5, there are at
-					// least two chips that export "2712_WAKE" as the line name.
-					if _, ok := registeredPins[line.Name()]; ok {
-						// This is a duplicate name. Prefix the line name with the
-						// chip name.
-						line.name = chip.Name() + "-" + line.Name()
-						if _, found := registeredPins[line.Name()]; found {
-							// It's still not unique. Skip it.
-							continue
-						}
-					}
-					registeredPins[line.Name()] = struct{}{}
-					if err = gpioreg.Register(line); err != nil {
-						log.Println("chip", chip.Name(), " gpioreg.Register(line) ", line, " returned ", err)
-					}
-				}
-			}
+			mID[id] = struct{}{}
+			chip.registerLines(registeredPins)
 		}
 	}
 	return len(Chips) > 0, nil
 }
 
+// registerLines registers the lines of the chip with gpioreg.
+// registeredPins holds the names already registered and is updated.
+//
+// Unnamed lines are named <chip name>-<offset>, like gpioinfo does, so they
+// are reachable via gpioreg.ByName().
+func (chip *GPIOChip) registerLines(registeredPins map[string]struct{}) {
+	for _, line := range chip.lines {
+		if len(line.name) == 0 || line.name == "_" || line.name == "-" {
+			line.name = chip.Name() + "-" + strconv.Itoa(line.Number())
+		}
+		// See if the name is already registered. On the Pi5, there are at
+		// least two chips that export "2712_WAKE" as the line name.
+		if _, ok := registeredPins[line.Name()]; ok {
+			// This is a duplicate name. Prefix the line name with the
+			// chip name.
+			line.name = chip.Name() + "-" + line.Name()
+			if _, found := registeredPins[line.Name()]; found {
+				// It's still not unique. Skip it.
+				continue
+			}
+		}
+		registeredPins[line.Name()] = struct{}{}
+		if err := gpioreg.Register(line); err != nil {
+			log.Println("chip", chip.Name(), " gpioreg.Register(line) ", line, " returned ", err)
+		}
+	}
+}
+
+// deviceID returns an identity for the device node backing the chip. Paths
+// symlinked to the same node return the same value.
+func (chip *GPIOChip) deviceID() string {
+	if chip.file != nil {
+		if fi, err := chip.file.Stat(); err == nil {
+			if dev, ino, ok := syscall_inode_wrapper(fi); ok {
+				return strconv.FormatUint(dev, 10) + ":" + strconv.FormatUint(ino, 10)
+			}
+		}
+	}
+	if p, err := filepath.EvalSymlinks(chip.path); err == nil {
+		return p
+	}
+	return chip.path
+}
+
+// sortChips sorts chips so the chip set via SetPreferredChip() comes first,
+// followed by those labeled with pinctrl- (a Pi kernel standard). Otherwise,
+// sort them by label, then by the kernel's gpiochip number. This _should_
+// protect us from any random changes in chip naming/ordering.
+func sortChips(chips []*GPIOChip) {
+	rank := func(c *GPIOChip) int {
+		if preferredChip != "" && c.Label() == preferredChip {
+			return 0
+		}
+		if strings.HasPrefix(c.Label(), "pinctrl-") {
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(chips, func(i, j int) bool {
+		I := chips[i]
+		J := chips[j]
+		if rI, rJ := rank(I), rank(J); rI != rJ {
+			return rI < rJ
+		}
+		if I.Label() != J.Label() {
+			return I.Label() < J.Label()
+		}
+		return chipNumber(I) < chipNumber(J)
+	})
+}
+
+// chipNumber returns the kernel's gpiochip number, e.g. 4 for gpiochip4, or
+// -1 if the name doesn't follow this pattern.
+func chipNumber(c *GPIOChip) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(c.Name(), "gpiochip"))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
 var drvGPIO driverGPIO
 
 func init() {
@@ -695,3 +1677,5 @@ var _ gpio.PinIO = &GPIOLine{}
 var _ gpio.PinIn = &GPIOLine{}
 var _ gpio.PinOut = &GPIOLine{}
 var _ pin.PinFunc = &GPIOLine{}
+var _ gpio.PinIO = &invertedLine{}
+var _ pin.PinFunc = &invertedLine{}
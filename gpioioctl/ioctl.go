@@ -11,7 +11,7 @@ package gpioioctl
 // https://docs.kernel.org/userspace-api/gpio/index.html
 
 import (
-	"errors"
+	"syscall"
 	"unsafe"
 )
 
@@ -140,55 +140,79 @@ type gpio_v2_line_event struct {
 	Padding      [6]uint32
 }
 
-func ioctl_get_gpio_v2_line_values(fd uintptr, data *gpio_v2_line_values) error {
+// IoctlError is returned when an ioctl() call on a GPIO chip or line fails.
+//
+// It preserves the syscall.Errno so callers can tell the failure reasons
+// apart with errors.Is(), e.g. syscall.EBUSY when the line is already
+// requested by another consumer, syscall.EACCES (or os.ErrPermission) when
+// the process lacks access, and syscall.EINVAL when the kernel rejected the
+// requested configuration.
+type IoctlError struct {
+	// Op is the name of the failed ioctl, as defined in linux/gpio.h.
+	Op string
+	// Errno is the error returned by the kernel.
+	Errno syscall.Errno
+}
+
+func (e *IoctlError) Error() string {
+	return e.Op + ": " + e.Errno.Error()
+}
+
+// Unwrap returns the underlying syscall.Errno.
+func (e *IoctlError) Unwrap() error {
+	return e.Errno
+}
+
+func kernel_ioctl_get_gpio_v2_line_values(fd uintptr, data *gpio_v2_line_values) error {
 	arg := _IOWR(0xb4, 0x0e, unsafe.Sizeof(gpio_v2_line_values{}))
 	_, _, ep := syscall_wrapper(_IOCTL_FUNCTION, fd, arg, uintptr(unsafe.Pointer(data)))
 	if ep != 0 {
-		return errors.New(ep.Error())
+		return &IoctlError{Op: "GPIO_V2_LINE_GET_VALUES_IOCTL", Errno: ep}
 	}
 	return nil
 }
 
-func ioctl_set_gpio_v2_line_values(fd uintptr, data *gpio_v2_line_values) error {
+func kernel_ioctl_set_gpio_v2_line_values(fd uintptr, data *gpio_v2_line_values) error {
 	arg := _IOWR(0xb4, 0x0f, unsafe.Sizeof(gpio_v2_line_values{}))
 	_, _, ep := syscall_wrapper(_IOCTL_FUNCTION, fd, arg, uintptr(unsafe.Pointer(data)))
 	if ep != 0 {
-		return errors.New(ep.Error())
+		return &IoctlError{Op: "GPIO_V2_LINE_SET_VALUES_IOCTL", Errno: ep}
 	}
 	return nil
 }
-func ioctl_gpiochip_info(fd uintptr, data *gpiochip_info) error {
+
+func kernel_ioctl_gpiochip_info(fd uintptr, data *gpiochip_info) error {
 	arg := _IOR(0xb4, 0x01, unsafe.Sizeof(gpiochip_info{}))
 	_, _, ep := syscall_wrapper(_IOCTL_FUNCTION, fd, arg, uintptr(unsafe.Pointer(data)))
 	if ep != 0 {
-		return errors.New(ep.Error())
+		return &IoctlError{Op: "GPIO_GET_CHIPINFO_IOCTL", Errno: ep}
 	}
 	return nil
 }
 
-func ioctl_gpio_v2_line_info(fd uintptr, data *gpio_v2_line_info) error {
+func kernel_ioctl_gpio_v2_line_info(fd uintptr, data *gpio_v2_line_info) error {
 	arg := _IOWR(0xb4, 0x05, unsafe.Sizeof(gpio_v2_line_info{}))
 	_, _, ep := syscall_wrapper(_IOCTL_FUNCTION, fd, arg, uintptr(unsafe.Pointer(data)))
 	if ep != 0 {
-		return errors.New(ep.Error())
+		return &IoctlError{Op: "GPIO_V2_GET_LINEINFO_IOCTL", Errno: ep}
 	}
 	return nil
 }
 
-func ioctl_gpio_v2_line_config(fd uintptr, data *gpio_v2_line_config) error {
+func kernel_ioctl_gpio_v2_line_config(fd uintptr, data *gpio_v2_line_config) error {
 	arg := _IOWR(0xb4, 0x0d, unsafe.Sizeof(gpio_v2_line_config{}))
 	_, _, ep := syscall_wrapper(_IOCTL_FUNCTION, fd, arg, uintptr(unsafe.Pointer(data)))
 	if ep != 0 {
-		return errors.New(ep.Error())
+		return &IoctlError{Op: "GPIO_V2_LINE_SET_CONFIG_IOCTL", Errno: ep}
 	}
 	return nil
 }
 
-func ioctl_gpio_v2_line_request(fd uintptr, data *gpio_v2_line_request) error {
+func kernel_ioctl_gpio_v2_line_request(fd uintptr, data *gpio_v2_line_request) error {
 	arg := _IOWR(0xb4, 0x07, unsafe.Sizeof(gpio_v2_line_request{}))
 	_, _, ep := syscall_wrapper(_IOCTL_FUNCTION, fd, arg, uintptr(unsafe.Pointer(data)))
 	if ep != 0 {
-		return errors.New(ep.Error())
+		return &IoctlError{Op: "GPIO_V2_GET_LINE_IOCTL", Errno: ep}
 	}
 	return nil
 }
@@ -0,0 +1,34 @@
+//go:build !gpioioctl_fake || windows
+
+// Copyright 2026 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+//
+// Without the gpioioctl_fake build tag, the ioctl calls go straight to the
+// kernel. See fake.go for the in-memory backend.
+
+package gpioioctl
+
+func ioctl_get_gpio_v2_line_values(fd uintptr, data *gpio_v2_line_values) error {
+	return kernel_ioctl_get_gpio_v2_line_values(fd, data)
+}
+
+func ioctl_set_gpio_v2_line_values(fd uintptr, data *gpio_v2_line_values) error {
+	return kernel_ioctl_set_gpio_v2_line_values(fd, data)
+}
+
+func ioctl_gpiochip_info(fd uintptr, data *gpiochip_info) error {
+	return kernel_ioctl_gpiochip_info(fd, data)
+}
+
+func ioctl_gpio_v2_line_info(fd uintptr, data *gpio_v2_line_info) error {
+	return kernel_ioctl_gpio_v2_line_info(fd, data)
+}
+
+func ioctl_gpio_v2_line_config(fd uintptr, data *gpio_v2_line_config) error {
+	return kernel_ioctl_gpio_v2_line_config(fd, data)
+}
+
+func ioctl_gpio_v2_line_request(fd uintptr, data *gpio_v2_line_request) error {
+	return kernel_ioctl_gpio_v2_line_request(fd, data)
+}
@@ -5,17 +5,23 @@ package gpioioctl
 // that can be found in the LICENSE file.
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"periph.io/x/conn/v3/gpio"
 	"periph.io/x/conn/v3/physic"
+	"periph.io/x/conn/v3/pin"
 )
 
 // LineConfigOverride is an override for a LineSet configuration.
@@ -27,35 +33,88 @@ type LineConfigOverride struct {
 	Direction LineDir
 	Edge      gpio.Edge
 	Pull      gpio.Pull
+	// Debounce is the kernel debounce period to apply to the lines. If 0, no
+	// debounce is configured. The kernel resolution is 1µs.
+	Debounce time.Duration
+}
+
+// numAttrs returns the number of gpio_v2_line_config_attribute required to
+// represent the override.
+func (lco *LineConfigOverride) numAttrs() int {
+	if lco.Debounce > 0 {
+		return 2
+	}
+	return 1
 }
 
 // LineSetConfig is used to create a structure for a LineSet request.
 // It allows you to specify the default configuration for lines, as well
 // as provide overrides for specific lines within the set.
+//
+// A Pull of gpio.PullNoChange, the zero value, leaves the bias of the pads
+// as-is, while gpio.Float disables it.
+//
+// The kernel limits a line request to 64 lines of a single chip, so Lines,
+// including the ones added by the overrides, can't have more than 64 entries.
 type LineSetConfig struct {
 	Lines            []string
 	DefaultDirection LineDir
 	DefaultEdge      gpio.Edge
 	DefaultPull      gpio.Pull
 	Overrides        []*LineConfigOverride
+	// OutputValues are the initial levels of the output lines, applied
+	// atomically with the line request. Output lines not listed start Low.
+	OutputValues map[string]gpio.Level
 }
 
 // AddOverrides adds a set of override values for specified lines. If a line
 // specified is not already part of the configuration line set, it's dynamically
 // added.
 func (cfg *LineSetConfig) AddOverrides(direction LineDir, edge gpio.Edge, pull gpio.Pull, lines ...string) error {
-	if len(cfg.Overrides) == _GPIO_V2_LINE_NUM_ATTRS_MAX {
-		return fmt.Errorf("a maximum of %d override entries can be configured", _GPIO_V2_LINE_NUM_ATTRS_MAX)
+	return cfg.AddDebouncedOverrides(direction, edge, pull, 0, lines...)
+}
+
+// AddDebouncedOverrides is like AddOverrides, but additionally configures a
+// kernel debounce period for the specified lines. This permits requesting a
+// debounced, edge detecting input in one atomic line request.
+//
+// An override with a debounce consumes two of the _GPIO_V2_LINE_NUM_ATTRS_MAX
+// attributes available, one for the flags and one for the debounce period.
+func (cfg *LineSetConfig) AddDebouncedOverrides(direction LineDir, edge gpio.Edge, pull gpio.Pull, debounce time.Duration, lines ...string) error {
+	if err := validateFlags(direction, edge, pull); err != nil {
+		return err
 	}
+	lco := &LineConfigOverride{Lines: lines, Direction: direction, Edge: edge, Pull: pull, Debounce: debounce}
+	if cfg.numAttrs()+lco.numAttrs() > _GPIO_V2_LINE_NUM_ATTRS_MAX {
+		return fmt.Errorf("a maximum of %d override attributes can be configured", _GPIO_V2_LINE_NUM_ATTRS_MAX)
+	}
+	var added []string
 	for _, l := range lines {
-		if cfg.getLineOffset(l) < 0 {
-			cfg.Lines = append(cfg.Lines, l)
+		if cfg.getLineOffset(l) < 0 && !slices.Contains(added, l) {
+			added = append(added, l)
 		}
 	}
-	cfg.Overrides = append(cfg.Overrides, &LineConfigOverride{Lines: lines, Direction: direction, Edge: edge, Pull: pull})
+	if n := len(cfg.Lines) + len(added); n > _GPIO_V2_LINES_MAX {
+		return fmt.Errorf("the override brings the line set to %d lines; a maximum of %d lines can be requested", n, _GPIO_V2_LINES_MAX)
+	}
+	cfg.Lines = append(cfg.Lines, added...)
+	cfg.Overrides = append(cfg.Overrides, lco)
 	return nil
 }
 
+// numAttrs returns the number of gpio_v2_line_config_attribute used by the
+// overrides.
+func (cfg *LineSetConfig) numAttrs() int {
+	n := 0
+	for _, lco := range cfg.Overrides {
+		n += lco.numAttrs()
+	}
+	if len(cfg.OutputValues) != 0 {
+		n++
+	}
+	return n
+}
+
 func (cfg *LineSetConfig) getLineOffset(lineName string) int {
 	for ix, name := range cfg.Lines {
 		if name == lineName {
@@ -67,7 +126,24 @@ func (cfg *LineSetConfig) getLineOffset(lineName string) int {
 
 // Return a gpio_v2_line_request that represents this LineSetConfig.
 // the returned value can then be used to request the lines.
-func (cfg *LineSetConfig) getLineSetRequestStruct(lineNumbers []uint32) *gpio_v2_line_request {
+func (cfg *LineSetConfig) getLineSetRequestStruct(lineNumbers []uint32) (*gpio_v2_line_request, error) {
+	if n := cfg.numAttrs(); n > _GPIO_V2_LINE_NUM_ATTRS_MAX {
+		return nil, fmt.Errorf("%d override attributes requested; a maximum of %d can be configured", n, _GPIO_V2_LINE_NUM_ATTRS_MAX)
+	}
+	if n := len(cfg.Lines); n > _GPIO_V2_LINES_MAX {
+		return nil, fmt.Errorf("%d lines requested; a maximum of %d lines can be requested", n, _GPIO_V2_LINES_MAX)
+	}
+	if len(lineNumbers) != len(cfg.Lines) {
+		return nil, fmt.Errorf("%d line numbers for %d lines", len(lineNumbers), len(cfg.Lines))
+	}
+	if err := validateFlags(cfg.DefaultDirection, cfg.DefaultEdge, cfg.DefaultPull); err != nil {
+		return nil, err
+	}
+	for _, lco := range cfg.Overrides {
+		if err := validateFlags(lco.Direction, lco.Edge, lco.Pull); err != nil {
+			return nil, fmt.Errorf("override %v: %w", lco.Lines, err)
+		}
+	}
 
 	var lr gpio_v2_line_request
 	for ix, char := range []byte(consumer) {
@@ -88,9 +164,30 @@ func (cfg *LineSetConfig) getLineSetRequestStruct(lineNumbers []uint32) *gpio_v2
 		}
 		lr.config.attrs[lr.config.num_attrs] = gpio_v2_line_config_attribute{attr: attr, mask: mask}
 		lr.config.num_attrs += 1
+		if lco.Debounce > 0 {
+			attr = gpio_v2_line_attribute{id: _GPIO_V2_LINE_ATTR_ID_DEBOUNCE, value: uint64(lco.Debounce / time.Microsecond)}
+			lr.config.attrs[lr.config.num_attrs] = gpio_v2_line_config_attribute{attr: attr, mask: mask}
+			lr.config.num_attrs += 1
+		}
+	}
+	if len(cfg.OutputValues) != 0 {
+		var mask, bits uint64
+		for line, l := range cfg.OutputValues {
+			offset := cfg.getLineOffset(line)
+			if offset < 0 {
+				return nil, fmt.Errorf("output value for line %q which is not part of the set", line)
+			}
+			mask |= uint64(1) << offset
+			if l {
+				bits |= uint64(1) << offset
+			}
+		}
+		attr := gpio_v2_line_attribute{id: _GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES, value: bits}
+		lr.config.attrs[lr.config.num_attrs] = gpio_v2_line_config_attribute{attr: attr, mask: mask}
+		lr.config.num_attrs += 1
 	}
 
-	return &lr
+	return &lr, nil
 }
 
 // LineSet is a set of GPIO lines that can be manipulated as one device.
@@ -109,11 +206,23 @@ func (cfg *LineSetConfig) getLineSetRequestStruct(lineNumbers []uint32) *gpio_v2
 // https://docs.kernel.org/userspace-api/gpio/gpio-v2-get-line-ioctl.html
 type LineSet struct {
 	lines []*LineSetLine
-	mu    sync.Mutex
+	// Index of lines by name, built on the first ByName() call.
+	byName     map[string]*LineSetLine
+	byNameOnce sync.Once
+	mu         sync.Mutex
 	// The anonymous file descriptor for this set of lines.
 	fd int32
+	// The chip this LineSet was requested from.
+	chip *GPIOChip
 	// The file required for edge detection.
 	fEdge *os.File
+	// halted is set by Halt() and consumed by the next wait, so a Halt() that
+	// races ahead of WaitForEdge() is not lost.
+	halted atomic.Bool
+	// The last event sequence number seen and the number of events the kernel
+	// dropped, deduced from the gaps in the sequence.
+	lastSeqno atomic.Uint32
+	missed    atomic.Uint64
 }
 
 // Close the anonymous file descriptor allocated for this LineSet and release
@@ -132,7 +241,11 @@ func (ls *LineSet) Close() error {
 	}
 	ls.fd = 0
 	ls.fEdge = nil
-	// TODO: This really needs erased from GPIOChip.LineSets
+	if ls.chip != nil {
+		ls.chip.mu.Lock()
+		ls.chip.lineSets = slices.DeleteFunc(ls.chip.lineSets, func(other *LineSet) bool { return other == ls })
+		ls.chip.mu.Unlock()
+	}
 	return err
 }
 
@@ -149,11 +262,11 @@ func (ls *LineSet) Lines() []*LineSetLine {
 
 // Interrupt any calls to WaitForEdge().
 func (ls *LineSet) Halt() error {
+	ls.halted.Store(true)
 	if ls.fEdge != nil {
 		return ls.fEdge.SetReadDeadline(time.UnixMilli(0))
 	}
 	return nil
-
 }
 
 // Out writes the set of bits to the LineSet's lines. If mask is 0, then the
@@ -192,6 +305,291 @@ func (ls *LineSet) Read(mask uint64) (uint64, error) {
 	return lvalues.bits, nil
 }
 
+// OffsetOf returns the offset within the LineSet of the line name, or -1 if
+// the line is not part of the set.
+//
+// The offset is the bit position used in the Out() and Read() masks. It is
+// unrelated to the line Number(), which is the offset within the chip.
+func (ls *LineSet) OffsetOf(name string) int {
+	if line := ls.ByName(name); line != nil {
+		return int(line.offset)
+	}
+	return -1
+}
+
+// OutLevels writes the levels to the lines, specified by name, in a single
+// operation. It saves the caller from computing the bits and mask for Out().
+func (ls *LineSet) OutLevels(levels map[string]gpio.Level) error {
+	var bits, mask uint64
+	for name, l := range levels {
+		offset := ls.OffsetOf(name)
+		if offset < 0 {
+			return fmt.Errorf("OutLevels(): line %s is not in the LineSet", name)
+		}
+		mask |= 1 << offset
+		if l {
+			bits |= 1 << offset
+		}
+	}
+	if mask == 0 {
+		return nil
+	}
+	return ls.Out(bits, mask)
+}
+
+// OutOrdered writes the levels to the lines in a single operation, where
+// levels[i] is the level of Lines()[i], e.g. to drive a parallel data bus.
+//
+// If levels is shorter than the LineSet, the remaining lines are left as-is.
+func (ls *LineSet) OutOrdered(levels []gpio.Level) error {
+	if len(levels) > ls.LineCount() {
+		return fmt.Errorf("OutOrdered(): %d levels for %d lines", len(levels), ls.LineCount())
+	}
+	var bits, mask uint64
+	for offset, l := range levels {
+		mask |= 1 << offset
+		if l {
+			bits |= 1 << offset
+		}
+	}
+	if mask == 0 {
+		return nil
+	}
+	return ls.Out(bits, mask)
+}
+
+// ReadLevels reads the lines specified by name in a single operation and
+// returns their levels keyed by name. If no name is specified, all the lines
+// are read.
+func (ls *LineSet) ReadLevels(names ...string) (map[string]gpio.Level, error) {
+	if len(names) == 0 {
+		for _, line := range ls.lines {
+			names = append(names, line.Name())
+		}
+	}
+	var mask uint64
+	for _, name := range names {
+		offset := ls.OffsetOf(name)
+		if offset < 0 {
+			return nil, fmt.Errorf("ReadLevels(): line %s is not in the LineSet", name)
+		}
+		mask |= 1 << offset
+	}
+	bits, err := ls.Read(mask)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]gpio.Level, len(names))
+	for _, name := range names {
+		out[name] = bits&(1<<ls.OffsetOf(name)) != 0
+	}
+	return out, nil
+}
+
+// ReadPhysical reads the actual pad level of the lines in this LineSet,
+// including the lines configured as outputs. mask is a bitmask of set pins to
+// read. If 0, then all pins are read.
+//
+// The output lines are momentarily reconfigured as inputs to sample the pad,
+// then restored to drive their previous value. mismatch has a bit set for each
+// output line whose pad level differs from the driven value, e.g. because of
+// a short. This is meant for hardware bring-up; the outputs float while they
+// are sampled.
+func (ls *LineSet) ReadPhysical(mask uint64) (bits, mismatch uint64, err error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if mask == 0 {
+		mask = (1 << ls.LineCount()) - 1
+	}
+	var outputs uint64
+	for _, line := range ls.lines {
+		if line.direction == LineOutput {
+			outputs |= 1 << line.offset
+		}
+	}
+	var driven gpio_v2_line_values
+	driven.mask = (1 << ls.LineCount()) - 1
+	if err = ioctl_get_gpio_v2_line_values(uintptr(ls.fd), &driven); err != nil {
+		return 0, 0, fmt.Errorf("ReadPhysical(): %w", err)
+	}
+	if outputs&mask == 0 {
+		return driven.bits & mask, 0, nil
+	}
+
+	cfg, err := ls.lineConfig(true, 0)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ReadPhysical(): %w", err)
+	}
+	if err = ioctl_gpio_v2_line_config(uintptr(ls.fd), cfg); err != nil {
+		return 0, 0, fmt.Errorf("ReadPhysical(): %w", err)
+	}
+	var physical gpio_v2_line_values
+	physical.mask = mask
+	errRead := ioctl_get_gpio_v2_line_values(uintptr(ls.fd), &physical)
+
+	// Always restore the outputs, even if the read failed.
+	if cfg, err = ls.lineConfig(false, driven.bits); err == nil {
+		err = ioctl_gpio_v2_line_config(uintptr(ls.fd), cfg)
+	}
+	if err = errors.Join(errRead, err); err != nil {
+		return 0, 0, fmt.Errorf("ReadPhysical(): %w", err)
+	}
+	return physical.bits, (physical.bits ^ driven.bits) & outputs & mask, nil
+}
+
+// SetDebounce changes the kernel debounce period of the lines, specified by
+// name, without releasing the LineSet. A period of 0 disables debouncing. The
+// direction, edge and pull of all the lines are preserved, and the outputs
+// keep driving their current value.
+func (ls *LineSet) SetDebounce(names []string, d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("SetDebounce(): invalid debounce %s", d)
+	}
+	var lines []*LineSetLine
+	for _, name := range names {
+		line := ls.ByName(name)
+		if line == nil {
+			return fmt.Errorf("SetDebounce(): line %s is not in the LineSet", name)
+		}
+		lines = append(lines, line)
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	var driven gpio_v2_line_values
+	driven.mask = (1 << ls.LineCount()) - 1
+	if err := ioctl_get_gpio_v2_line_values(uintptr(ls.fd), &driven); err != nil {
+		return fmt.Errorf("SetDebounce(): %w", err)
+	}
+	old := make([]time.Duration, len(lines))
+	for i, line := range lines {
+		old[i] = line.debounce
+		line.debounce = d
+	}
+	cfg, err := ls.lineConfig(false, driven.bits)
+	if err == nil {
+		err = ioctl_gpio_v2_line_config(uintptr(ls.fd), cfg)
+	}
+	if err != nil {
+		for i, line := range lines {
+			line.debounce = old[i]
+		}
+		return fmt.Errorf("SetDebounce(): %w", err)
+	}
+	return nil
+}
+
+// Reconfigure changes the direction, edge detection, bias and debounce of the
+// lines without releasing the LineSet. cfg.Lines must list the lines of the
+// LineSet in the same order.
+//
+// The levels in cfg.OutputValues are applied in the same kernel operation as
+// the new configuration, so a line changed to an output comes up at the
+// intended level without a glitch. The output lines that are not listed keep
+// driving their current value if they were already outputs, and start Low
+// otherwise.
+func (ls *LineSet) Reconfigure(cfg *LineSetConfig) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.reconfigureLocked(cfg)
+}
+
+// reconfigureLocked is Reconfigure() with ls.mu held.
+func (ls *LineSet) reconfigureLocked(cfg *LineSetConfig) error {
+	if len(cfg.Lines) != len(ls.lines) {
+		return fmt.Errorf("Reconfigure(): %d lines in the configuration for %d lines in the LineSet", len(cfg.Lines), len(ls.lines))
+	}
+	numbers := make([]uint32, len(ls.lines))
+	for i, line := range ls.lines {
+		if cfg.Lines[i] != line.name {
+			return fmt.Errorf("Reconfigure(): line %d is %s in the configuration but %s in the LineSet", i, cfg.Lines[i], line.name)
+		}
+		numbers[i] = line.number
+	}
+	var driven gpio_v2_line_values
+	driven.mask = (1 << ls.LineCount()) - 1
+	if err := ioctl_get_gpio_v2_line_values(uintptr(ls.fd), &driven); err != nil {
+		return fmt.Errorf("Reconfigure(): %w", err)
+	}
+	next := make([]*LineSetLine, len(ls.lines))
+	values := maps.Clone(cfg.OutputValues)
+	for i, line := range ls.lines {
+		next[i] = ls.chip.newLineSetLine(int(line.number), i, cfg)
+		if line.direction != LineOutput || next[i].direction != LineOutput {
+			continue
+		}
+		if _, ok := values[line.name]; !ok {
+			if values == nil {
+				values = make(map[string]gpio.Level)
+			}
+			values[line.name] = driven.bits&(uint64(1)<<i) != 0
+		}
+	}
+	c := *cfg
+	c.OutputValues = values
+	req, err := c.getLineSetRequestStruct(numbers)
+	if err != nil {
+		return fmt.Errorf("Reconfigure(): %w", err)
+	}
+	if err := ioctl_gpio_v2_line_config(uintptr(ls.fd), &req.config); err != nil {
+		return fmt.Errorf("Reconfigure(): %w", err)
+	}
+	for i, line := range ls.lines {
+		line.direction = next[i].direction
+		line.edge = next[i].edge
+		line.pull = next[i].pull
+		line.debounce = next[i].debounce
+	}
+	return nil
+}
+
+// lineConfig returns the line configuration matching the current state of
+// the lines. If outputsAsInputs is true, the output lines are configured as
+// inputs. Otherwise the output lines drive values.
+func (ls *LineSet) lineConfig(outputsAsInputs bool, values uint64) (*gpio_v2_line_config, error) {
+	type group struct {
+		attr gpio_v2_line_attribute
+		mask uint64
+	}
+	var groups []group
+	add := func(attr gpio_v2_line_attribute, bit uint64) {
+		for i := range groups {
+			if groups[i].attr == attr {
+				groups[i].mask |= bit
+				return
+			}
+		}
+		groups = append(groups, group{attr: attr, mask: bit})
+	}
+	var outputs uint64
+	for _, line := range ls.lines {
+		bit := uint64(1) << line.offset
+		dir := line.direction
+		if dir == LineOutput {
+			if outputsAsInputs {
+				dir = LineInput
+			} else {
+				outputs |= bit
+			}
+		}
+		add(gpio_v2_line_attribute{id: _GPIO_V2_LINE_ATTR_ID_FLAGS, value: getFlags(dir, line.edge, line.pull)}, bit)
+		if line.debounce > 0 {
+			add(gpio_v2_line_attribute{id: _GPIO_V2_LINE_ATTR_ID_DEBOUNCE, value: uint64(line.debounce / time.Microsecond)}, bit)
+		}
+	}
+	if outputs != 0 {
+		groups = append(groups, group{attr: gpio_v2_line_attribute{id: _GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES, value: values}, mask: outputs})
+	}
+	if len(groups) > _GPIO_V2_LINE_NUM_ATTRS_MAX {
+		return nil, fmt.Errorf("%d attributes needed; a maximum of %d can be configured", len(groups), _GPIO_V2_LINE_NUM_ATTRS_MAX)
+	}
+	var cfg gpio_v2_line_config
+	for _, g := range groups {
+		cfg.attrs[cfg.num_attrs] = gpio_v2_line_config_attribute{attr: g.attr, mask: g.mask}
+		cfg.num_attrs++
+	}
+	return &cfg, nil
+}
+
 func (ls *LineSet) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		Lines []*LineSetLine `json:"Lines"`
@@ -206,50 +604,264 @@ func (ls *LineSet) String() string {
 	return string(json)
 }
 
+// LineEvent is an edge event reported by the kernel for a line of a LineSet.
+type LineEvent struct {
+	// Number is the GPIO chip line number that triggered the event.
+	Number uint32
+	// Edge is the edge detected, either gpio.RisingEdge or gpio.FallingEdge.
+	Edge gpio.Edge
+	// Timestamp is the time the kernel detected the edge. By default, it is
+	// read from CLOCK_MONOTONIC.
+	Timestamp time.Duration
+	// Seqno is the sequence number of the event across all the lines of the
+	// LineSet. A jump in the sequence indicates that events were dropped.
+	Seqno uint32
+	// LineSeqno is the sequence number of the event for this specific line.
+	LineSeqno uint32
+}
+
+func newLineEvent(event *gpio_v2_line_event) LineEvent {
+	le := LineEvent{
+		Number:    event.Offset,
+		Edge:      gpio.NoEdge,
+		Timestamp: time.Duration(event.Timestamp_ns),
+		Seqno:     event.Seqno,
+		LineSeqno: event.LineSeqno,
+	}
+	if event.Id == _GPIO_V2_LINE_EVENT_RISING_EDGE {
+		le.Edge = gpio.RisingEdge
+	} else if event.Id == _GPIO_V2_LINE_EVENT_FALLING_EDGE {
+		le.Edge = gpio.FallingEdge
+	}
+	return le
+}
+
 // WaitForEdge waits for an edge to be triggered on the LineSet.
 //
 // Returns:
 //
-// number - the number of the line that was triggered.
+// line - the line that was triggered.
 //
 // edge - The edge value. gpio.Edge. If a timeout or halt occurred,
 // then the edge returned will be gpio.NoEdge
 //
 // err - Error value if any.
-func (ls *LineSet) WaitForEdge(timeout time.Duration) (number uint32, edge gpio.Edge, err error) {
-	number = 0
-	edge = gpio.NoEdge
-	if ls.fEdge == nil {
-		err = syscall_nonblock_wrapper(int(ls.fd), true)
+func (ls *LineSet) WaitForEdge(timeout time.Duration) (line *LineSetLine, edge gpio.Edge, err error) {
+	le, err := ls.WaitForEvent(timeout)
+	if err != nil {
+		return nil, gpio.NoEdge, err
+	}
+	return ls.ByNumber(int(le.Number)), le.Edge, nil
+}
+
+// WaitForEdgeContext waits for an edge to be triggered on the LineSet, like
+// WaitForEdge(), until ctx is done. If ctx is canceled or its deadline expires
+// first, ctx.Err() is returned.
+func (ls *LineSet) WaitForEdgeContext(ctx context.Context) (line *LineSetLine, edge gpio.Edge, err error) {
+	le, err := ls.waitForEvent(ctx, 0)
+	if err != nil {
+		return nil, gpio.NoEdge, err
+	}
+	return ls.ByNumber(int(le.Number)), le.Edge, nil
+}
+
+// WaitForEdgeDebounced waits for an edge to be triggered on the LineSet, like
+// WaitForEdge(), then debounces it in software: the edge is only returned once
+// the level of the line has been stable for settle. The bounces of the line
+// are coalesced, and a glitch where the line settles back to its previous
+// level is ignored.
+//
+// This is a portable fallback for the controllers that don't support the
+// kernel debounce, where the request fails with EINVAL. Hardware debounce, see
+// LineSetConfig.AddDebouncedOverrides() and SetDebounce(), is preferred when
+// available as it doesn't depend on the scheduling latency and doesn't wake
+// up the process on each bounce.
+//
+// The edges on the other lines of the LineSet received while settling are
+// discarded.
+//
+// timeout for the first edge to occur. If 0, waits forever. Settling may
+// extend the wait past timeout.
+func (ls *LineSet) WaitForEdgeDebounced(settle, timeout time.Duration) (line *LineSetLine, edge gpio.Edge, err error) {
+	if settle <= 0 {
+		return nil, gpio.NoEdge, fmt.Errorf("WaitForEdgeDebounced() - invalid settle time %s", settle)
+	}
+	var deadline time.Time
+	if timeout != 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		var wait time.Duration
+		if !deadline.IsZero() {
+			if wait = time.Until(deadline); wait <= 0 {
+				return nil, gpio.NoEdge, fmt.Errorf("WaitForEdgeDebounced() - %w", os.ErrDeadlineExceeded)
+			}
+		}
+		le, err := ls.WaitForEvent(wait)
+		if err != nil {
+			return nil, gpio.NoEdge, err
+		}
+		line = ls.ByNumber(int(le.Number))
+		if line == nil {
+			return nil, gpio.NoEdge, fmt.Errorf("WaitForEdgeDebounced() - unknown line %d", le.Number)
+		}
+		l, err := ls.settleLevel(line, settle)
+		if err != nil {
+			return nil, gpio.NoEdge, err
+		}
+		if l && le.Edge == gpio.RisingEdge {
+			return line, gpio.RisingEdge, nil
+		}
+		if !l && le.Edge == gpio.FallingEdge {
+			return line, gpio.FallingEdge, nil
+		}
+		// It was a glitch, wait for the next edge.
+	}
+}
+
+// settleLevel returns the level of line once no edge was received on it for
+// settle.
+func (ls *LineSet) settleLevel(line *LineSetLine, settle time.Duration) (gpio.Level, error) {
+	stable := time.Now().Add(settle)
+	for wait := settle; wait > 0; wait = time.Until(stable) {
+		le, err := ls.WaitForEvent(wait)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		}
 		if err != nil {
-			err = fmt.Errorf("WaitForEdge() - SetNonblock: %w", err)
-			return
+			return gpio.Low, err
+		}
+		if le.Number == line.number {
+			stable = time.Now().Add(settle)
+		}
+	}
+	mask := uint64(1) << line.offset
+	bits, err := ls.Read(mask)
+	if err != nil {
+		return gpio.Low, err
+	}
+	return bits&mask != 0, nil
+}
+
+// WaitForEvent waits for an edge to be triggered on the LineSet and returns
+// the full event as reported by the kernel, including the sequence numbers
+// which can be used to detect dropped edges.
+//
+// timeout for the edge change to occur. If 0, waits forever. If a timeout or
+// halt occurred, an error is returned.
+func (ls *LineSet) WaitForEvent(timeout time.Duration) (LineEvent, error) {
+	return ls.waitForEvent(context.Background(), timeout)
+}
+
+// errHalted is returned when the wait was aborted by a Halt() call that
+// preceded it.
+var errHalted = errors.New("WaitForEvent() - halted")
+
+// prepareWait readies the file descriptor for reading events, with a read
+// deadline of timeout, 0 meaning forever.
+func (ls *LineSet) prepareWait(timeout time.Duration) error {
+	if ls.fEdge == nil {
+		if err := syscall_nonblock_wrapper(int(ls.fd), true); err != nil {
+			return fmt.Errorf("WaitForEvent() - SetNonblock: %w", err)
 		}
 		ls.fEdge = os.NewFile(uintptr(ls.fd), "gpio-lineset")
 	}
 
+	var err error
 	if timeout == 0 {
 		err = ls.fEdge.SetReadDeadline(time.Time{})
 	} else {
 		err = ls.fEdge.SetReadDeadline(time.Now().Add(timeout))
 	}
 	if err != nil {
-		err = fmt.Errorf("WaitForEdge() - SetReadDeadline(): %w", err)
-		return
+		return fmt.Errorf("WaitForEvent() - SetReadDeadline(): %w", err)
 	}
+	// Checked after setting the deadline, so a Halt() called from now on
+	// interrupts the read.
+	if ls.halted.Swap(false) {
+		return errHalted
+	}
+	return nil
+}
 
-	var event gpio_v2_line_event
-	err = binary.Read(ls.fEdge, binary.LittleEndian, &event)
+// DrainEvents waits for an edge to be triggered on the LineSet, like
+// WaitForEvent(), then returns up to maxEvents of the events queued by the
+// kernel, read in one operation.
+//
+// This is faster than calling WaitForEvent() repeatedly when several lines
+// change at nearly the same time, e.g. on a parallel bus strobe, and the
+// events are returned in the order the kernel detected them.
+//
+// timeout for the first edge to occur. If 0, waits forever. If a timeout or
+// halt occurred, an error is returned.
+func (ls *LineSet) DrainEvents(maxEvents int, timeout time.Duration) ([]LineEvent, error) {
+	if maxEvents <= 0 {
+		return nil, fmt.Errorf("DrainEvents() - invalid maximum number of events %d", maxEvents)
+	}
+	if err := ls.prepareWait(timeout); err != nil {
+		return nil, err
+	}
+	size := binary.Size(gpio_v2_line_event{})
+	buf := make([]byte, maxEvents*size)
+	n, err := ls.fEdge.Read(buf)
 	if err != nil {
-		return
+		// This wait consumed the Halt(), if any.
+		ls.halted.Store(false)
+		return nil, err
 	}
-	if event.Id == _GPIO_V2_LINE_EVENT_RISING_EDGE {
-		edge = gpio.RisingEdge
-	} else if event.Id == _GPIO_V2_LINE_EVENT_FALLING_EDGE {
-		edge = gpio.FallingEdge
+	if n%size != 0 {
+		return nil, fmt.Errorf("DrainEvents() - read %d bytes, not a multiple of the event size", n)
 	}
-	number = uint32(event.Offset)
-	return
+	events := make([]LineEvent, 0, n/size)
+	r := bytes.NewReader(buf[:n])
+	for r.Len() != 0 {
+		var event gpio_v2_line_event
+		if err := binary.Read(r, binary.LittleEndian, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, ls.trackEvent(newLineEvent(&event)))
+	}
+	return events, nil
+}
+
+func (ls *LineSet) waitForEvent(ctx context.Context, timeout time.Duration) (LineEvent, error) {
+	if err := ls.prepareWait(timeout); err != nil {
+		return LineEvent{}, err
+	}
+	stop := interruptOnDone(ctx, ls.fEdge)
+	defer stop()
+
+	var event gpio_v2_line_event
+	if err := binary.Read(ls.fEdge, binary.LittleEndian, &event); err != nil {
+		// This wait consumed the Halt(), if any.
+		ls.halted.Store(false)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return LineEvent{}, ctxErr
+		}
+		return LineEvent{}, err
+	}
+	return ls.trackEvent(newLineEvent(&event)), nil
+}
+
+// trackEvent accounts for the events dropped by the kernel before le, as
+// reported by MissedEvents(), and returns le.
+func (ls *LineSet) trackEvent(le LineEvent) LineEvent {
+	// The kernel sequence numbers start at 1.
+	if last := ls.lastSeqno.Swap(le.Seqno); le.Seqno > last+1 {
+		ls.missed.Add(uint64(le.Seqno - last - 1))
+	}
+	return le
+}
+
+// MissedEvents returns the number of edge events dropped by the kernel since
+// the LineSet was requested, typically because its event buffer overflowed
+// as the events were not read fast enough.
+//
+// The drops are detected from the gaps in the sequence numbers of the events
+// read, so the events dropped after the last event read are not accounted
+// for yet.
+func (ls *LineSet) MissedEvents() uint64 {
+	return ls.missed.Load()
 }
 
 // ByOffset returns a line by it's offset in the LineSet.
@@ -262,13 +874,15 @@ func (ls *LineSet) ByOffset(offset int) *LineSetLine {
 
 // ByName returns a Line by name from the LineSet.
 func (ls *LineSet) ByName(name string) *LineSetLine {
-
-	for _, line := range ls.lines {
-		if line.Name() == name {
-			return line
+	ls.byNameOnce.Do(func() {
+		ls.byName = make(map[string]*LineSetLine, len(ls.lines))
+		for _, line := range ls.lines {
+			if _, ok := ls.byName[line.Name()]; !ok {
+				ls.byName[line.Name()] = line
+			}
 		}
-	}
-	return nil
+	})
+	return ls.byName[name]
 }
 
 // LineNumber Return a line from the LineSet via it's GPIO line
@@ -295,6 +909,7 @@ type LineSetLine struct {
 	direction LineDir
 	pull      gpio.Pull
 	edge      gpio.Edge
+	debounce  time.Duration
 }
 
 /*
@@ -311,8 +926,93 @@ func (lsl *LineSetLine) Name() string {
 	return lsl.name
 }
 
+// Deprecated: Use PinFunc.Func. Will be removed in v4. Function implements pin.Pin.
 func (lsl *LineSetLine) Function() string {
-	return "not implemented"
+	return string(lsl.Func())
+}
+
+// Func implements pin.PinFunc.
+func (lsl *LineSetLine) Func() pin.Func {
+	switch lsl.direction {
+	case LineInput:
+		if lsl.Read() {
+			return gpio.IN_HIGH
+		}
+		return gpio.IN_LOW
+	case LineOutput:
+		// The kernel returns the driven value for output lines.
+		if lsl.Read() {
+			return gpio.OUT_HIGH
+		}
+		return gpio.OUT_LOW
+	}
+	return pin.FuncNone
+}
+
+// SupportedFuncs implements pin.PinFunc.
+func (lsl *LineSetLine) SupportedFuncs() []pin.Func {
+	return []pin.Func{gpio.IN, gpio.OUT}
+}
+
+// SetFunc implements pin.PinFunc.
+//
+// Changing the direction of the line goes through LineSet.Reconfigure(), so
+// the other lines of the LineSet are left as-is. An input loses its edge
+// detection.
+func (lsl *LineSetLine) SetFunc(f pin.Func) error {
+	switch f {
+	case gpio.IN:
+		if lsl.direction == LineInput {
+			return nil
+		}
+		return lsl.reconfigure(LineInput, gpio.NoEdge, lsl.pull, gpio.Low)
+	case gpio.OUT, gpio.OUT_LOW, gpio.OUT_HIGH:
+		l := gpio.Level(f == gpio.OUT_HIGH)
+		if lsl.direction == LineOutput {
+			return lsl.Out(l)
+		}
+		return lsl.reconfigure(LineOutput, gpio.NoEdge, lsl.pull, l)
+	default:
+		return errors.New("unsupported function")
+	}
+}
+
+// reconfigure changes the configuration of the line through
+// LineSet.Reconfigure(), leaving the other lines of the LineSet as-is. l is
+// the level driven when dir is LineOutput.
+func (lsl *LineSetLine) reconfigure(dir LineDir, edge gpio.Edge, pull gpio.Pull, l gpio.Level) error {
+	ls := lsl.parent
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	cfg := &LineSetConfig{Lines: make([]string, len(ls.lines))}
+	for i, line := range ls.lines {
+		cfg.Lines[i] = line.name
+		lco := LineConfigOverride{Direction: line.direction, Edge: line.edge, Pull: line.pull, Debounce: line.debounce}
+		if line == lsl {
+			lco = LineConfigOverride{Direction: dir, Edge: edge, Pull: pull}
+			if dir == LineInput {
+				lco.Debounce = line.debounce
+			}
+		}
+		// Group the lines sharing the same configuration, as the kernel only
+		// supports a few attributes per request.
+		found := false
+		for _, o := range cfg.Overrides {
+			if o.Direction == lco.Direction && o.Edge == lco.Edge && o.Pull == lco.Pull && o.Debounce == lco.Debounce {
+				o.Lines = append(o.Lines, line.name)
+				found = true
+				break
+			}
+		}
+		if !found {
+			lco.Lines = []string{line.name}
+			cfg.Overrides = append(cfg.Overrides, &lco)
+		}
+	}
+	if dir == LineOutput {
+		cfg.OutputValues = map[string]gpio.Level{lsl.name: l}
+	}
+	return ls.reconfigureLocked(cfg)
 }
 
 func (lsl *LineSetLine) Direction() LineDir {
@@ -323,6 +1023,12 @@ func (lsl *LineSetLine) Edge() gpio.Edge {
 	return lsl.edge
 }
 
+// Debounce returns the kernel debounce period configured for the line, or 0
+// if none.
+func (lsl *LineSetLine) Debounce() time.Duration {
+	return lsl.debounce
+}
+
 // Out writes to this specific GPIO line.
 func (lsl *LineSetLine) Out(l gpio.Level) error {
 	var mask, bits uint64
@@ -345,10 +1051,10 @@ func (lsl *LineSetLine) Halt() error {
 	return errors.New("you can't halt an individual line in a LineSet. you must halt the LineSet")
 }
 
-// In configures the line for input. Since individual lines in a
-// LineSet cannot be re-configured this always returns an error.
+// In configures the line for input through LineSet.Reconfigure(), leaving
+// the other lines of the LineSet as-is.
 func (lsl *LineSetLine) In(pull gpio.Pull, edge gpio.Edge) error {
-	return errors.New("a LineSet line cannot be re-configured")
+	return lsl.reconfigure(LineInput, edge, pull, gpio.Low)
 }
 
 // Read returns the value of this specific line.
@@ -414,3 +1120,4 @@ func (lsl *LineSetLine) Offset() uint32 {
 var _ gpio.PinIO = &LineSetLine{}
 var _ gpio.PinIn = &LineSetLine{}
 var _ gpio.PinOut = &LineSetLine{}
+var _ pin.PinFunc = &LineSetLine{}
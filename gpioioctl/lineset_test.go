@@ -0,0 +1,257 @@
+// Copyright 2026 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpioioctl
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/pin"
+)
+
+func TestLineSetConfigDebounce(t *testing.T) {
+	cfg := LineSetConfig{Lines: []string{"A", "B"}, DefaultDirection: LineOutput}
+	if err := cfg.AddDebouncedOverrides(LineInput, gpio.BothEdges, gpio.PullUp, 10*time.Millisecond, "C"); err != nil {
+		t.Fatal(err)
+	}
+	req, err := cfg.getLineSetRequestStruct([]uint32{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.num_lines != 3 {
+		t.Errorf("num_lines = %d", req.num_lines)
+	}
+	if req.config.num_attrs != 2 {
+		t.Fatalf("num_attrs = %d", req.config.num_attrs)
+	}
+	flags := req.config.attrs[0]
+	if flags.attr.id != _GPIO_V2_LINE_ATTR_ID_FLAGS || flags.mask != 1<<2 {
+		t.Errorf("flags attribute = %+v", flags)
+	}
+	if flags.attr.value != getFlags(LineInput, gpio.BothEdges, gpio.PullUp) {
+		t.Errorf("flags = %#x", flags.attr.value)
+	}
+	debounce := req.config.attrs[1]
+	if debounce.attr.id != _GPIO_V2_LINE_ATTR_ID_DEBOUNCE || debounce.mask != 1<<2 || debounce.attr.value != 10000 {
+		t.Errorf("debounce attribute = %+v", debounce)
+	}
+}
+
+func TestLineSetConfigOutputValues(t *testing.T) {
+	cfg := LineSetConfig{
+		Lines:            []string{"A", "B", "C"},
+		DefaultDirection: LineOutput,
+		OutputValues:     map[string]gpio.Level{"A": gpio.High, "C": gpio.Low},
+	}
+	req, err := cfg.getLineSetRequestStruct([]uint32{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.config.num_attrs != 1 {
+		t.Fatalf("num_attrs = %d", req.config.num_attrs)
+	}
+	values := req.config.attrs[0]
+	if values.attr.id != _GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES || values.mask != 0b101 || values.attr.value != 0b001 {
+		t.Errorf("output values attribute = %+v", values)
+	}
+	cfg.OutputValues["D"] = gpio.High
+	if _, err := cfg.getLineSetRequestStruct([]uint32{1, 2, 3}); err == nil {
+		t.Error("expected error for a line not in the set")
+	}
+}
+
+func TestLineSetConfigMaxAttrs(t *testing.T) {
+	cfg := LineSetConfig{}
+	for i := 0; i < _GPIO_V2_LINE_NUM_ATTRS_MAX/2; i++ {
+		if err := cfg.AddDebouncedOverrides(LineInput, gpio.NoEdge, gpio.PullUp, time.Millisecond, string(rune('A'+i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cfg.AddOverrides(LineInput, gpio.NoEdge, gpio.PullUp, "Z") == nil {
+		t.Fatal("expected error when exceeding the number of attributes")
+	}
+	cfg.Overrides = append(cfg.Overrides, &LineConfigOverride{Lines: []string{"Z"}})
+	if _, err := cfg.getLineSetRequestStruct(make([]uint32, len(cfg.Lines))); err == nil {
+		t.Fatal("expected error when exceeding the number of attributes")
+	}
+}
+
+func TestNewLineEvent(t *testing.T) {
+	event := gpio_v2_line_event{Timestamp_ns: 1500, Id: _GPIO_V2_LINE_EVENT_FALLING_EDGE, Offset: 17, Seqno: 4, LineSeqno: 2}
+	le := newLineEvent(&event)
+	expected := LineEvent{Number: 17, Edge: gpio.FallingEdge, Timestamp: 1500 * time.Nanosecond, Seqno: 4, LineSeqno: 2}
+	if le != expected {
+		t.Errorf("newLineEvent() = %+v; expected %+v", le, expected)
+	}
+	event.Id = _GPIO_V2_LINE_EVENT_RISING_EDGE
+	if le = newLineEvent(&event); le.Edge != gpio.RisingEdge {
+		t.Errorf("Edge = %s", le.Edge)
+	}
+}
+
+func TestLineSetMissedEvents(t *testing.T) {
+	ls := &LineSet{}
+	for _, seqno := range []uint32{1, 2, 5, 6, 9} {
+		ls.trackEvent(LineEvent{Seqno: seqno})
+	}
+	if m := ls.MissedEvents(); m != 4 {
+		t.Fatalf("MissedEvents() = %d", m)
+	}
+}
+
+func TestLineSetWaitForEdgeContext(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	ls := &LineSet{fEdge: r}
+	defer r.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(time.Millisecond)
+		cancel()
+	}()
+	if _, _, err := ls.WaitForEdgeContext(ctx); err != context.Canceled {
+		t.Fatal(err)
+	}
+}
+
+func TestLineSetLineConfig(t *testing.T) {
+	ls := &LineSet{lines: []*LineSetLine{
+		{offset: 0, direction: LineOutput},
+		{offset: 1, direction: LineInput, pull: gpio.PullUp, edge: gpio.BothEdges, debounce: time.Millisecond},
+		{offset: 2, direction: LineOutput},
+	}}
+	cfg, err := ls.lineConfig(true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Outputs as inputs, the input line and its debounce.
+	if cfg.num_attrs != 3 {
+		t.Fatal(cfg.num_attrs)
+	}
+	if a := cfg.attrs[0]; a.mask != 0x5 || a.attr.value != getFlags(LineInput, gpio.NoEdge, gpio.PullNoChange) {
+		t.Fatalf("%+v", a)
+	}
+	if a := cfg.attrs[2]; a.mask != 0x2 || a.attr.id != _GPIO_V2_LINE_ATTR_ID_DEBOUNCE || a.attr.value != 1000 {
+		t.Fatalf("%+v", a)
+	}
+	if cfg, err = ls.lineConfig(false, 0x4); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.num_attrs != 4 {
+		t.Fatal(cfg.num_attrs)
+	}
+	if a := cfg.attrs[3]; a.mask != 0x5 || a.attr.id != _GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES || a.attr.value != 0x4 {
+		t.Fatalf("%+v", a)
+	}
+}
+
+func TestLineSetOffsetOf(t *testing.T) {
+	ls := &LineSet{lines: []*LineSetLine{
+		{offset: 0, number: 17, name: "GPIO17"},
+		{offset: 1, number: 4, name: "GPIO4"},
+	}}
+	if o := ls.OffsetOf("GPIO4"); o != 1 {
+		t.Fatal(o)
+	}
+	if o := ls.OffsetOf("GPIO5"); o != -1 {
+		t.Fatal(o)
+	}
+	if err := ls.OutLevels(map[string]gpio.Level{"GPIO5": gpio.High}); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := ls.ReadLevels("GPIO5"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLineSetLineSetFunc(t *testing.T) {
+	lsl := &LineSetLine{direction: LineInput}
+	if f := lsl.SupportedFuncs(); len(f) != 2 || f[0] != gpio.IN || f[1] != gpio.OUT {
+		t.Errorf("SupportedFuncs() = %v", f)
+	}
+	if err := lsl.SetFunc(gpio.IN); err != nil {
+		t.Error(err)
+	}
+	if err := lsl.SetFunc(pin.FuncNone); err == nil {
+		t.Error("expected error for an unsupported function")
+	}
+}
+
+func TestLineSetSetDebounce(t *testing.T) {
+	line := &LineSetLine{name: "A", direction: LineInput, debounce: time.Millisecond}
+	ls := &LineSet{lines: []*LineSetLine{line}, fd: -1}
+	if err := ls.SetDebounce([]string{"B"}, time.Millisecond); err == nil {
+		t.Error("expected error for a line not in the set")
+	}
+	if err := ls.SetDebounce([]string{"A"}, -time.Millisecond); err == nil {
+		t.Error("expected error for a negative debounce")
+	}
+	// The ioctl fails on the invalid file descriptor; the previous debounce
+	// must be kept.
+	if err := ls.SetDebounce([]string{"A"}, 5*time.Millisecond); err == nil {
+		t.Error("expected ioctl error")
+	}
+	if line.debounce != time.Millisecond {
+		t.Errorf("debounce = %s", line.debounce)
+	}
+}
+
+func TestLineSetHaltBeforeWait(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	ls := &LineSet{fEdge: r}
+	defer r.Close()
+	if err := ls.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	// The Halt() must not be lost even though no wait was in progress.
+	if _, _, err := ls.WaitForEdge(0); err != errHalted {
+		t.Fatal(err)
+	}
+	// It is consumed by the wait.
+	if _, _, err := ls.WaitForEdge(time.Millisecond); err == nil || err == errHalted {
+		t.Fatal(err)
+	}
+}
+
+func TestLineSetConfigMaxLines(t *testing.T) {
+	cfg := LineSetConfig{DefaultDirection: LineInput}
+	for i := 0; i < 60; i++ {
+		cfg.Lines = append(cfg.Lines, fmt.Sprintf("L%d", i))
+	}
+	var extra []string
+	for i := 60; i < 66; i++ {
+		extra = append(extra, fmt.Sprintf("L%d", i))
+	}
+	if err := cfg.AddOverrides(LineOutput, gpio.NoEdge, gpio.PullNoChange, extra...); err == nil {
+		t.Fatal("expected error growing the config past 64 lines")
+	}
+	if len(cfg.Lines) != 60 || len(cfg.Overrides) != 0 {
+		t.Fatalf("config modified on error: %d lines, %d overrides", len(cfg.Lines), len(cfg.Overrides))
+	}
+	// Lines already in the set don't count twice.
+	if err := cfg.AddOverrides(LineOutput, gpio.NoEdge, gpio.PullNoChange, "L0", "L60", "L61", "L62", "L63", "L63"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Lines) != 64 {
+		t.Fatalf("%d lines", len(cfg.Lines))
+	}
+	// A config grown directly is rejected when building the request.
+	cfg.Lines = append(cfg.Lines, "L64")
+	numbers := make([]uint32, len(cfg.Lines))
+	if _, err := cfg.getLineSetRequestStruct(numbers); err == nil {
+		t.Fatal("expected error building a request with 65 lines")
+	}
+}
@@ -12,6 +12,7 @@
 package gpioioctl
 
 import (
+	"os"
 	"syscall"
 )
 
@@ -30,3 +31,17 @@ func syscall_close_wrapper(fd int) (err error) {
 func syscall_nonblock_wrapper(fd int, nonblocking bool) (err error) {
 	return syscall.SetNonblock(fd, nonblocking)
 }
+
+func syscall_inode_wrapper(fi os.FileInfo) (dev, ino uint64, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(st.Dev), uint64(st.Ino), true
+}
+
+func syscall_pipe_wrapper() (r, w int, err error) {
+	var p [2]int
+	err = syscall.Pipe(p[:])
+	return p[0], p[1], err
+}
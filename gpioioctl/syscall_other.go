@@ -6,6 +6,7 @@
 package gpioioctl
 
 import (
+	"os"
 	"syscall"
 )
 
@@ -26,3 +27,13 @@ func syscall_close_wrapper(fd int) (err error) {
 func syscall_nonblock_wrapper(fd int, nonblocking bool) (err error) {
 	return syscall.SetNonblock(syscall.Handle(fd), nonblocking)
 }
+
+func syscall_inode_wrapper(fi os.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
+
+func syscall_pipe_wrapper() (r, w int, err error) {
+	var p [2]syscall.Handle
+	err = syscall.Pipe(p[:])
+	return int(p[0]), int(p[1]), err
+}
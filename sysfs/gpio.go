@@ -10,7 +10,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -33,11 +35,44 @@ import (
 // mutated afterward. Do not modify it.
 var Pins map[int]*Pin
 
+// probeExport is set via SetProbeExport.
+var probeExport bool
+
+// SetProbeExport enables probing each pin by exporting it at initialization.
+//
+// The chip drivers may list GPIO pins that cannot be exported, which then
+// fail on first use. When enabled, these pins are not registered. The pins
+// exported by the probe are unexported right away. It slows down the
+// initialization, so it is disabled by default.
+//
+// It must be called before host.Init().
+func SetProbeExport(probe bool) {
+	probeExport = probe
+}
+
+// PinsByChip returns all the pins exported by GPIO sysfs grouped by the
+// gpiochip they belong to.
+//
+// The key is the gpiochip sysfs path, e.g. /sys/class/gpio/gpiochip0, and the
+// pins are sorted by number.
+func PinsByChip() map[string][]*Pin {
+	out := map[string][]*Pin{}
+	for _, p := range Pins {
+		out[p.chip] = append(out[p.chip], p)
+	}
+	for _, pins := range out {
+		sort.Slice(pins, func(i, j int) bool { return pins[i].number < pins[j].number })
+	}
+	return out
+}
+
 // Pin represents one GPIO pin as found by sysfs.
 type Pin struct {
 	number int
 	name   string
 	root   string // Something like /sys/class/gpio/gpio%d/
+	chip   string // Something like /sys/class/gpio/gpiochip%d
+	base   int    // First pin number of the gpiochip
 
 	mu         sync.Mutex
 	err        error     // If open() failed
@@ -74,6 +109,26 @@ func (p *Pin) Number() int {
 	return p.number
 }
 
+// Chip returns the sysfs path of the gpiochip this pin belongs to, e.g.
+// /sys/class/gpio/gpiochip0.
+func (p *Pin) Chip() string {
+	return p.chip
+}
+
+// ChipBase returns the global sysfs number of the first pin of the gpiochip
+// this pin belongs to.
+func (p *Pin) ChipBase() int {
+	return p.base
+}
+
+// Offset returns the offset of this pin within its gpiochip.
+//
+// This is the line number used by the gpio character device, which permits to
+// correlate a sysfs pin with the matching gpioioctl line.
+func (p *Pin) Offset() int {
+	return p.number - p.base
+}
+
 // Function implements pin.Pin.
 func (p *Pin) Function() string {
 	return string(p.Func())
@@ -457,14 +512,34 @@ func (d *driverGPIO) Init() (bool, error) {
 		return false, errors.New("no GPIO pin found")
 	}
 
+	var unexport io.Writer
+	if probeExport {
+		// Probing requires both handles before parsing the chips.
+		if drvGPIO.exportHandle, err = fileIOOpen("/sys/class/gpio/export", os.O_WRONLY); err != nil {
+			if os.IsPermission(err) {
+				err = fmt.Errorf("need more access, try as root or setup udev rules: %v", err)
+			}
+			return true, err
+		}
+		f, err := fileIOOpen("/sys/class/gpio/unexport", os.O_WRONLY)
+		if err != nil {
+			return true, err
+		}
+		defer f.Close()
+		unexport = f
+	}
+
 	// There are hosts that use non-continuous pin numbering so use a map instead
 	// of an array.
 	Pins = map[int]*Pin{}
 	for _, item := range items {
-		if err = d.parseGPIOChip(item + "/"); err != nil {
+		if err = d.parseGPIOChip(item+"/", unexport); err != nil {
 			return true, err
 		}
 	}
+	if drvGPIO.exportHandle != nil {
+		return true, nil
+	}
 	drvGPIO.exportHandle, err = fileIOOpen("/sys/class/gpio/export", os.O_WRONLY)
 	if os.IsPermission(err) {
 		return true, fmt.Errorf("need more access, try as root or setup udev rules: %v", err)
@@ -472,7 +547,26 @@ func (d *driverGPIO) Init() (bool, error) {
 	return true, err
 }
 
-func (d *driverGPIO) parseGPIOChip(path string) error {
+// exportable returns true if the pin can be exported. The pin is unexported
+// back if it was exported by this function.
+func (d *driverGPIO) exportable(number int, root string, unexport io.Writer) bool {
+	if f, err := fileIOOpen(root+"value", os.O_RDONLY); err == nil {
+		// Already exported.
+		_ = f.Close()
+		return true
+	}
+	n := []byte(strconv.Itoa(number))
+	if _, err := d.exportHandle.Write(n); err != nil {
+		// Including EBUSY, which means a kernel driver claimed the line.
+		return false
+	}
+	_, _ = unexport.Write(n)
+	return true
+}
+
+// parseGPIOChip registers the pins of a gpiochip. When unexport is not nil,
+// the pins that cannot be exported are skipped.
+func (d *driverGPIO) parseGPIOChip(path string, unexport io.Writer) error {
 	base, err := readInt(path + "base")
 	if err != nil {
 		return err
@@ -481,8 +575,9 @@ func (d *driverGPIO) parseGPIOChip(path string) error {
 	if err != nil {
 		return err
 	}
-	// TODO(maruel): The chip driver may lie and lists GPIO pins that cannot be
-	// exported. The only way to know about it is to export it before opening.
+	// The chip driver may lie and lists GPIO pins that cannot be exported. The
+	// only way to know about it is to export it before opening, which is only
+	// done when requested via SetProbeExport().
 	for i := base; i < base+number; i++ {
 		if _, ok := Pins[i]; ok {
 			return fmt.Errorf("found two pins with number %d", i)
@@ -491,6 +586,11 @@ func (d *driverGPIO) parseGPIOChip(path string) error {
 			number: i,
 			name:   fmt.Sprintf("GPIO%d", i),
 			root:   fmt.Sprintf("/sys/class/gpio/gpio%d/", i),
+			chip:   strings.TrimSuffix(path, "/"),
+			base:   base,
+		}
+		if unexport != nil && !d.exportable(i, p.root, unexport) {
+			continue
 		}
 		Pins[i] = p
 		if err := gpioreg.Register(p); err != nil {
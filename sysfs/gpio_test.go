@@ -6,6 +6,10 @@ package sysfs
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
 	"testing"
 
 	"periph.io/x/conn/v3/gpio"
@@ -34,6 +38,38 @@ func TestPin_Number(t *testing.T) {
 	}
 }
 
+func TestPin_Chip(t *testing.T) {
+	p := Pin{number: 42, name: "foo", root: "/tmp/gpio/priv/", chip: "/tmp/gpio/gpiochip32", base: 32}
+	if s := p.Chip(); s != "/tmp/gpio/gpiochip32" {
+		t.Fatal(s)
+	}
+	if b := p.ChipBase(); b != 32 {
+		t.Fatal(b)
+	}
+	if o := p.Offset(); o != 10 {
+		t.Fatal(o)
+	}
+}
+
+func TestPinsByChip(t *testing.T) {
+	defer func(old map[int]*Pin) { Pins = old }(Pins)
+	Pins = map[int]*Pin{
+		33: {number: 33, chip: "/tmp/gpio/gpiochip32"},
+		32: {number: 32, chip: "/tmp/gpio/gpiochip32"},
+		0:  {number: 0, chip: "/tmp/gpio/gpiochip0"},
+	}
+	m := PinsByChip()
+	if len(m) != 2 {
+		t.Fatal(m)
+	}
+	if l := m["/tmp/gpio/gpiochip0"]; len(l) != 1 || l[0].number != 0 {
+		t.Fatal(l)
+	}
+	if l := m["/tmp/gpio/gpiochip32"]; len(l) != 2 || l[0].number != 32 || l[1].number != 33 {
+		t.Fatal(l)
+	}
+}
+
 func TestPin_Func(t *testing.T) {
 	p := Pin{number: 42, name: "foo", root: "/tmp/gpio/priv/"}
 	// Fails because open is not mocked.
@@ -209,8 +245,45 @@ func TestGPIODriver(t *testing.T) {
 	}
 }
 
+func TestGPIODriver_exportable(t *testing.T) {
+	defer reset()
+	fileIOOpen = func(path string, flag int) (fileIO, error) {
+		if path == "/sys/class/gpio/gpio1/value" {
+			return &fakeGPIOFile{}, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	busy := &os.PathError{Op: "write", Path: "/sys/class/gpio/export", Err: syscall.EBUSY}
+	export := &fakeWriter{fail: map[string]error{"3": errors.New("injected"), "4": busy}}
+	unexport := &fakeWriter{}
+	d := driverGPIO{exportHandle: export}
+	for i, want := range []bool{true, true, true, false, false} {
+		if got := d.exportable(i, fmt.Sprintf("/sys/class/gpio/gpio%d/", i), unexport); got != want {
+			t.Errorf("exportable(%d) = %t", i, got)
+		}
+	}
+	// gpio1 was already exported, gpio3 failed and gpio4 is claimed by a kernel
+	// driver; only 0 and 2 are unexported.
+	if got := strings.Join(unexport.w, ","); got != "0,2" {
+		t.Errorf("unexported %s", got)
+	}
+}
+
 //
 
+type fakeWriter struct {
+	fail map[string]error
+	w    []string
+}
+
+func (f *fakeWriter) Write(b []byte) (int, error) {
+	if err := f.fail[string(b)]; err != nil {
+		return 0, err
+	}
+	f.w = append(f.w, string(b))
+	return len(b), nil
+}
+
 type fakeGPIOFile struct {
 	data []byte
 }